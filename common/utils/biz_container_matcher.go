@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/koupleless/virtual-kubelet/model"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DefaultBizContainerMatcher is the matcher used when none is configured, containers with jar images are biz modules
+var DefaultBizContainerMatcher model.BizContainerMatcher = JarBizContainerMatcher{}
+
+var _ model.BizContainerMatcher = JarBizContainerMatcher{}
+var _ model.BizContainerMatcher = &ImagePatternBizContainerMatcher{}
+var _ model.BizContainerMatcher = AnnotationBizContainerMatcher{}
+var _ model.BizContainerMatcher = LabelBizContainerMatcher{}
+var _ model.BizContainerMatcher = AnyBizContainerMatcher{}
+
+// JarBizContainerMatcher matches the containers whose image is a jar
+type JarBizContainerMatcher struct{}
+
+func (JarBizContainerMatcher) IsBizContainer(_ *corev1.Pod, container *corev1.Container) bool {
+	return strings.Contains(container.Image, ".jar")
+}
+
+// ImagePatternBizContainerMatcher matches the containers whose image matches the pattern, e.g. OCI artifacts or zip bundles,
+// the jar images are matched if no pattern is set
+type ImagePatternBizContainerMatcher struct {
+	Pattern *regexp.Regexp
+}
+
+func (m *ImagePatternBizContainerMatcher) IsBizContainer(pod *corev1.Pod, container *corev1.Container) bool {
+	if m.Pattern == nil {
+		return JarBizContainerMatcher{}.IsBizContainer(pod, container)
+	}
+	return m.Pattern.MatchString(container.Image)
+}
+
+// AnnotationBizContainerMatcher matches the containers listed in the pod annotation of the key, names are separated by comma
+type AnnotationBizContainerMatcher struct {
+	Key string
+}
+
+func (m AnnotationBizContainerMatcher) IsBizContainer(pod *corev1.Pod, container *corev1.Container) bool {
+	for _, name := range strings.Split(pod.Annotations[m.Key], ",") {
+		if strings.TrimSpace(name) == container.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// LabelBizContainerMatcher matches all containers of the pods with the label, empty value matches any value of the label
+type LabelBizContainerMatcher struct {
+	Key   string
+	Value string
+}
+
+func (m LabelBizContainerMatcher) IsBizContainer(pod *corev1.Pod, _ *corev1.Container) bool {
+	value, has := pod.Labels[m.Key]
+	return has && (m.Value == "" || m.Value == value)
+}
+
+// AnyBizContainerMatcher matches the containers matched by any of the matchers
+type AnyBizContainerMatcher []model.BizContainerMatcher
+
+func (m AnyBizContainerMatcher) IsBizContainer(pod *corev1.Pod, container *corev1.Container) bool {
+	for _, matcher := range m {
+		if matcher.IsBizContainer(pod, container) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBizContainerMatchers(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{"biz": "true"},
+			Annotations: map[string]string{"biz-containers": "biz1, biz2"},
+		},
+	}
+	jar := &corev1.Container{Name: "biz1", Image: "biz1.jar"}
+	zip := &corev1.Container{Name: "biz3", Image: "biz3.zip"}
+
+	assert.True(t, DefaultBizContainerMatcher.IsBizContainer(pod, jar))
+	assert.False(t, DefaultBizContainerMatcher.IsBizContainer(pod, zip))
+
+	imageMatcher := &ImagePatternBizContainerMatcher{Pattern: regexp.MustCompile(`\.zip$`)}
+	assert.True(t, imageMatcher.IsBizContainer(pod, zip))
+	assert.False(t, imageMatcher.IsBizContainer(pod, jar))
+	assert.True(t, (&ImagePatternBizContainerMatcher{}).IsBizContainer(pod, jar))
+	assert.False(t, (&ImagePatternBizContainerMatcher{}).IsBizContainer(pod, zip))
+
+	annotationMatcher := AnnotationBizContainerMatcher{Key: "biz-containers"}
+	assert.True(t, annotationMatcher.IsBizContainer(pod, jar))
+	assert.False(t, annotationMatcher.IsBizContainer(pod, zip))
+
+	assert.True(t, LabelBizContainerMatcher{Key: "biz"}.IsBizContainer(pod, zip))
+	assert.False(t, LabelBizContainerMatcher{Key: "biz", Value: "false"}.IsBizContainer(pod, zip))
+
+	anyMatcher := AnyBizContainerMatcher{annotationMatcher, imageMatcher}
+	assert.True(t, anyMatcher.IsBizContainer(pod, jar))
+	assert.True(t, anyMatcher.IsBizContainer(pod, zip))
+	assert.False(t, anyMatcher.IsBizContainer(pod, &corev1.Container{Name: "sidecar", Image: "sidecar"}))
+}
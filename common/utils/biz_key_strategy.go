@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/koupleless/virtual-kubelet/model"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DefaultBizKeyStrategy is the strategy used when neither the config nor the tunnel provides one
+var DefaultBizKeyStrategy model.BizKeyStrategy = NameVersionBizKeyStrategy{}
+
+var _ model.BizKeyStrategy = NameVersionBizKeyStrategy{}
+
+// NameVersionBizKeyStrategy identifies a biz by the container name and the literal value of the BIZ_VERSION env, formatted as name:version
+type NameVersionBizKeyStrategy struct{}
+
+func (NameVersionBizKeyStrategy) BizUniqueKey(_ *corev1.Pod, container *corev1.Container) string {
+	return GetBizUniqueKey(container)
+}
+
+func (NameVersionBizKeyStrategy) ParseBizUniqueKey(key string) (*corev1.Container, error) {
+	bizName, bizVersion := GetBizNameAndVersionFromUniqueKey(key)
+	if bizName == "" {
+		return nil, fmt.Errorf("unexpected biz key format: %q", key)
+	}
+	if bizVersion == "" {
+		return nil, fmt.Errorf("no BIZ_VERSION in biz key %q", key)
+	}
+	return &corev1.Container{
+		Name: bizName,
+		Env: []corev1.EnvVar{{
+			Name:  "BIZ_VERSION",
+			Value: bizVersion,
+		}},
+	}, nil
+}
+
+// BizUniqueKeyGetter is implemented by the tunnels written before model.BizKeyStrategy, which only compute the unique key of biz containers
+type BizUniqueKeyGetter interface {
+	// GetBizUniqueKey returns the unique key of the biz container, the same as BizStatusData.Key reported by the tunnel
+	GetBizUniqueKey(container *corev1.Container) string
+}
+
+var _ model.BizKeyStrategy = LegacyBizKeyStrategy{}
+
+// LegacyBizKeyStrategy adapts a tunnel implementing BizUniqueKeyGetter to model.BizKeyStrategy,
+// the key is computed by the tunnel and parsed as name:version like NameVersionBizKeyStrategy
+type LegacyBizKeyStrategy struct {
+	Getter BizUniqueKeyGetter
+}
+
+func (s LegacyBizKeyStrategy) BizUniqueKey(_ *corev1.Pod, container *corev1.Container) string {
+	return s.Getter.GetBizUniqueKey(container)
+}
+
+func (LegacyBizKeyStrategy) ParseBizUniqueKey(key string) (*corev1.Container, error) {
+	return NameVersionBizKeyStrategy{}.ParseBizUniqueKey(key)
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNameVersionBizKeyStrategy(t *testing.T) {
+	container := &corev1.Container{
+		Name: "biz1",
+		Env:  []corev1.EnvVar{{Name: "BIZ_VERSION", Value: "0.0.1"}},
+	}
+	key := DefaultBizKeyStrategy.BizUniqueKey(&corev1.Pod{}, container)
+	assert.Equal(t, "biz1:0.0.1", key)
+
+	parsed, err := DefaultBizKeyStrategy.ParseBizUniqueKey(key)
+	assert.NoError(t, err)
+	assert.Equal(t, key, DefaultBizKeyStrategy.BizUniqueKey(&corev1.Pod{}, parsed))
+
+	_, err = DefaultBizKeyStrategy.ParseBizUniqueKey("invalid")
+	assert.Error(t, err)
+	_, err = DefaultBizKeyStrategy.ParseBizUniqueKey("biz1:")
+	assert.Error(t, err)
+}
+
+type bizUniqueKeyGetterFunc func(container *corev1.Container) string
+
+func (f bizUniqueKeyGetterFunc) GetBizUniqueKey(container *corev1.Container) string {
+	return f(container)
+}
+
+func TestLegacyBizKeyStrategy(t *testing.T) {
+	strategy := LegacyBizKeyStrategy{Getter: bizUniqueKeyGetterFunc(func(container *corev1.Container) string {
+		return container.Name + ":latest"
+	})}
+	key := strategy.BizUniqueKey(&corev1.Pod{}, &corev1.Container{Name: "biz1"})
+	assert.Equal(t, "biz1:latest", key)
+
+	parsed, err := strategy.ParseBizUniqueKey(key)
+	assert.NoError(t, err)
+	assert.Equal(t, key, strategy.BizUniqueKey(&corev1.Pod{}, parsed))
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koupleless/virtual-kubelet/model"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BizStateMapFunc sets the state and readiness of the container status for a biz state
+type BizStateMapFunc func(status *corev1.ContainerStatus, data *model.BizStatusData)
+
+var _ model.StatusMapper = &DefaultStatusMapper{}
+
+// DefaultStatusMapper maps the biz states of model.BizState to container states, and keeps restart count and last termination state.
+// When a biz goes from ACTIVATED to BROKEN, the termination is recorded as the last termination state. The restart count is kept
+// from the previous status, it is counted by the provider on the BROKEN to ACTIVATED transition. Tunnels can register the mapping of custom states.
+type DefaultStatusMapper struct {
+	customStates map[string]BizStateMapFunc
+}
+
+// NewDefaultStatusMapper creates a DefaultStatusMapper without custom states
+func NewDefaultStatusMapper() *DefaultStatusMapper {
+	return &DefaultStatusMapper{
+		customStates: make(map[string]BizStateMapFunc),
+	}
+}
+
+// RegisterState registers the mapping of a custom biz state, states are case-insensitive, must be called before the mapper is used
+func (m *DefaultStatusMapper) RegisterState(state string, mapFunc BizStateMapFunc) {
+	m.customStates[strings.ToUpper(state)] = mapFunc
+}
+
+func (m *DefaultStatusMapper) MapBizStatus(container *corev1.Container, previous *corev1.ContainerStatus, data *model.BizStatusData) (*corev1.ContainerStatus, error) {
+	// this may be a little complex to handle the case that parameters is not nil or for same container name
+	if previous == nil {
+		if data == nil || (container.Name != data.Name) {
+			started := false
+			return &corev1.ContainerStatus{
+				Name:        container.Name,
+				ContainerID: container.Name,
+				State:       corev1.ContainerState{},
+				Ready:       started,
+				Started:     &started,
+				Image:       container.Image,
+				ImageID:     container.Image,
+			}, nil
+		}
+	}
+
+	if data == nil {
+		// reuse the old status, cause the new data is nil
+		if previous.Name == container.Name {
+			return previous, nil
+		} else {
+			// can't handle this case
+			return nil, fmt.Errorf("convert biz status to container status but container name mismatch: %s != %s", previous.Name, container.Name)
+		}
+	}
+
+	if container.Name != data.Name {
+		// reuse the old status, cause the new data is not for this container
+		if previous.Name == container.Name {
+			return previous, nil
+		} else {
+			return nil, fmt.Errorf("convert biz status to container status but container name mismatch: %s != %s", container.Name, data.Name)
+		}
+	}
+
+	started := false
+	ret := corev1.ContainerStatus{
+		Name:        container.Name,
+		ContainerID: container.Name,
+		State:       corev1.ContainerState{},
+		Ready:       started,
+		Started:     &started,
+		Image:       container.Image,
+		ImageID:     container.Image,
+	}
+	if previous != nil {
+		ret.RestartCount = previous.RestartCount
+		previous.LastTerminationState.DeepCopyInto(&ret.LastTerminationState)
+	}
+
+	state := strings.ToUpper(data.State)
+	if mapFunc, has := m.customStates[state]; has {
+		mapFunc(&ret, data)
+		return &ret, nil
+	}
+
+	switch model.BizState(state) {
+	case model.BizStateUnResolved:
+		// no biz info yet
+	case model.BizStateResolved:
+		// the biz is starting
+		ret.State.Waiting = &corev1.ContainerStateWaiting{
+			Reason:  data.Reason,
+			Message: data.Message,
+		}
+	case model.BizStateDeactivated:
+		ret.State.Waiting = &corev1.ContainerStateWaiting{
+			Reason:  OrElse(data.Reason, model.ContainerReasonBizDeactivated),
+			Message: data.Message,
+		}
+	case model.BizStateBroken:
+		terminated := &corev1.ContainerStateTerminated{
+			ExitCode:   OrElse(data.ExitCode, 1),
+			Reason:     OrElse(data.Reason, model.ContainerReasonBizError),
+			Message:    data.Message,
+			FinishedAt: metav1.NewTime(data.ChangeTime),
+		}
+		if previous != nil && previous.State.Running != nil {
+			terminated.StartedAt = previous.State.Running.StartedAt
+		} else if previous != nil && previous.State.Waiting != nil && previous.LastTerminationState.Terminated != nil &&
+			(previous.State.Waiting.Reason == model.ContainerReasonBizBroken || previous.State.Waiting.Reason == model.ContainerReasonCrashLoopBackOff) {
+			// the same broken status is mapped again
+			terminated.StartedAt = previous.LastTerminationState.Terminated.StartedAt
+		}
+		ret.LastTerminationState = corev1.ContainerState{Terminated: terminated}
+		ret.State.Waiting = &corev1.ContainerStateWaiting{
+			Reason:  model.ContainerReasonBizBroken,
+			Message: data.Message,
+		}
+	case model.BizStateActivated:
+		ret.State.Running = &corev1.ContainerStateRunning{
+			StartedAt: metav1.NewTime(data.ChangeTime),
+		}
+		started = true
+		ret.Ready = true
+	case model.BizStateStopped:
+		terminated := &corev1.ContainerStateTerminated{
+			ExitCode:   data.ExitCode,
+			Reason:     OrElse(data.Reason, model.ContainerReasonBizCompleted),
+			Message:    data.Message,
+			FinishedAt: metav1.NewTime(data.ChangeTime),
+			StartedAt:  metav1.NewTime(data.ChangeTime),
+		}
+		if previous != nil && previous.State.Running != nil {
+			terminated.StartedAt = previous.State.Running.StartedAt
+		} else if previous != nil && previous.State.Terminated != nil {
+			// the same stopped status is mapped again
+			terminated.StartedAt = previous.State.Terminated.StartedAt
+		}
+		ret.State.Terminated = terminated
+	}
+
+	return &ret, nil
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/koupleless/virtual-kubelet/model"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestDefaultStatusMapper_RestartCount(t *testing.T) {
+	mapper := NewDefaultStatusMapper()
+	container := &corev1.Container{Name: "biz1", Image: "biz1.jar"}
+	now := time.Now()
+
+	status, err := mapper.MapBizStatus(container, nil, &model.BizStatusData{Name: "biz1", State: string(model.BizStateActivated), ChangeTime: now})
+	assert.NoError(t, err)
+	assert.NotNil(t, status.State.Running)
+	assert.True(t, status.Ready)
+
+	status, err = mapper.MapBizStatus(container, status, &model.BizStatusData{
+		Name:       "biz1",
+		State:      string(model.BizStateBroken),
+		ChangeTime: now.Add(time.Second),
+		Reason:     "OOM",
+		ExitCode:   137,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, model.ContainerReasonBizBroken, status.State.Waiting.Reason)
+	assert.Equal(t, int32(137), status.LastTerminationState.Terminated.ExitCode)
+	assert.Equal(t, "OOM", status.LastTerminationState.Terminated.Reason)
+	assert.Equal(t, now.Unix(), status.LastTerminationState.Terminated.StartedAt.Unix())
+	assert.Equal(t, int32(0), status.RestartCount)
+
+	// mapping the same broken status again keeps the last termination
+	brokenStatus := status.DeepCopy()
+	status, err = mapper.MapBizStatus(container, status, &model.BizStatusData{
+		Name:       "biz1",
+		State:      string(model.BizStateBroken),
+		ChangeTime: now.Add(time.Second),
+		Reason:     "OOM",
+		ExitCode:   137,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, brokenStatus, status)
+
+	status, err = mapper.MapBizStatus(container, status, &model.BizStatusData{Name: "biz1", State: string(model.BizStateActivated), ChangeTime: now.Add(2 * time.Second)})
+	assert.NoError(t, err)
+	assert.NotNil(t, status.State.Running)
+	assert.NotNil(t, status.LastTerminationState.Terminated)
+
+	// the restart counted by the provider is kept
+	status.RestartCount = 1
+
+	status, err = mapper.MapBizStatus(container, status, &model.BizStatusData{Name: "biz1", State: string(model.BizStateStopped), ChangeTime: now.Add(3 * time.Second)})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), status.State.Terminated.ExitCode)
+	assert.Equal(t, model.ContainerReasonBizCompleted, status.State.Terminated.Reason)
+	assert.Equal(t, int32(1), status.RestartCount)
+}
+
+func TestDefaultStatusMapper_CustomState(t *testing.T) {
+	mapper := NewDefaultStatusMapper()
+	mapper.RegisterState("upgrading", func(status *corev1.ContainerStatus, data *model.BizStatusData) {
+		status.State.Waiting = &corev1.ContainerStateWaiting{Reason: "Upgrading"}
+	})
+	status, err := mapper.MapBizStatus(&corev1.Container{Name: "biz1"}, nil, &model.BizStatusData{Name: "biz1", State: "UPGRADING"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Upgrading", status.State.Waiting.Reason)
+	assert.False(t, status.Ready)
+}
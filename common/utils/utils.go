@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -16,7 +17,6 @@ import (
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
@@ -232,98 +232,97 @@ func MergeNodeFromProvider(node *corev1.Node, data model.NodeStatusData) *corev1
 	return vnodeCopy // Return the constructed vnode.
 }
 
-// ConvertBizStatusToContainerStatus converts tunnel container status to Kubernetes container status, if not the status for the container, then create a empty state container status
-func ConvertBizStatusToContainerStatus(container *corev1.Container, containerStatus *corev1.ContainerStatus, data *model.BizStatusData) (*corev1.ContainerStatus, error) {
-	// this may be a little complex to handle the case that parameters is not nil or for same container name
-	if containerStatus == nil {
-		if data == nil || (container.Name != data.Name) {
-			started := false
-			return &corev1.ContainerStatus{
-				Name:        container.Name,
-				ContainerID: container.Name,
-				State:       corev1.ContainerState{},
-				Ready:       started,
-				Started:     &started,
-				Image:       container.Image,
-				ImageID:     container.Image,
-			}, nil
+// SetNodeCondition replaces the condition of the same type in the node status, or appends it if not exists.
+func SetNodeCondition(node *corev1.Node, condition corev1.NodeCondition) {
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == condition.Type {
+			node.Status.Conditions[i] = condition
+			return
 		}
 	}
+	node.Status.Conditions = append(node.Status.Conditions, condition)
+}
 
-	if data == nil {
-		// reuse the old status, cause the new data is nil
-		if containerStatus.Name == container.Name {
-			return containerStatus, nil
-		} else {
-			// can't handle this case
-			return nil, fmt.Errorf("convert biz status to container status but container name mismatch: %s != %s", containerStatus.Name, container.Name)
+// AddOrUpdateTaint returns the taints with the given taint added, a taint with the same key and effect will be replaced.
+func AddOrUpdateTaint(taints []corev1.Taint, taint corev1.Taint) []corev1.Taint {
+	ret := make([]corev1.Taint, 0, len(taints)+1)
+	for _, t := range taints {
+		if t.Key == taint.Key && t.Effect == taint.Effect {
+			continue
 		}
+		ret = append(ret, t)
 	}
+	return append(ret, taint)
+}
 
-	if container.Name != data.Name {
-		// reuse the old status, cause the new data is not for this container
-		if containerStatus.Name == container.Name {
-			return containerStatus, nil
-		} else {
-			return nil, fmt.Errorf("convert biz status to container status but container name mismatch: %s != %s", container.Name, data.Name)
+// RemoveTaint returns the taints without the taints of the given key.
+func RemoveTaint(taints []corev1.Taint, key string) []corev1.Taint {
+	ret := make([]corev1.Taint, 0, len(taints))
+	for _, t := range taints {
+		if t.Key != key {
+			ret = append(ret, t)
 		}
 	}
+	return ret
+}
 
-	// Determine if the container has started based on the data state
-	started := strings.EqualFold(data.State, string(model.BizStateActivated))
-
-	// Initialize the return value with common fields
-	ret := corev1.ContainerStatus{
-		Name:        container.Name,
-		ContainerID: container.Name,
-		State:       corev1.ContainerState{},
-		Ready:       started,
-		Started:     &started,
-		Image:       container.Image,
-		ImageID:     container.Image,
-	}
-
-	if strings.EqualFold(data.State, string(model.BizStateUnResolved)) {
-		// Handle the case where data is nil, indicating the container is pending
-		// no biz info yet
-		ret.State = corev1.ContainerState{}
-		return &ret, nil
-	} else if strings.EqualFold(data.State, string(model.BizStateResolved)) ||
-		strings.EqualFold(data.State, string(model.BizStateBroken)) ||
-		strings.EqualFold(data.State, string(model.BizStateDeactivated)) {
-		// Handle the case where the container is in the resolved state, indicating it's starting
-		ret.State.Waiting = &corev1.ContainerStateWaiting{
-			Reason:  data.Reason,
-			Message: data.Message,
-			// Note: This state indicates the container is in the process of starting
-		}
-		return &ret, nil
-	} else if strings.EqualFold(data.State, string(model.BizStateActivated)) {
-		// Handle the case where the container is in the activated state, indicating it's running
-		ret.State.Running = &corev1.ContainerStateRunning{
-			StartedAt: metav1.Time{
-				Time: data.ChangeTime,
-			},
-			// Note: This state indicates the container is currently running
-		}
-	} else if strings.EqualFold(data.State, string(model.BizStateStopped)) {
-		// Handle the case where the container is in the deactivated state, indicating it's terminated
-		ret.State.Terminated = &corev1.ContainerStateTerminated{
-			ExitCode: 1,
-			Reason:   data.Reason,
-			Message:  data.Message,
-			FinishedAt: metav1.Time{
-				Time: data.ChangeTime,
-			},
-			// TODO: set the start time of this biz?
-			StartedAt: metav1.Time{
-				Time: data.ChangeTime,
-			},
-			// Note: This state indicates the container has been terminated
+// VNodeTaints returns the taints every vnode of the env carries, vpods must tolerate them to be scheduled to vnodes.
+func VNodeTaints(env string) []corev1.Taint {
+	return []corev1.Taint{
+		{
+			Key:    model.TaintKeyOfVnode,
+			Value:  "True",
+			Effect: corev1.TaintEffectNoExecute,
+		},
+		{
+			Key:    model.TaintKeyOfEnv,
+			Value:  env,
+			Effect: corev1.TaintEffectNoExecute,
+		},
+	}
+}
+
+// VNodeTolerations returns the tolerations of the taints returned by VNodeTaints.
+func VNodeTolerations(env string) []corev1.Toleration {
+	taints := VNodeTaints(env)
+	tolerations := make([]corev1.Toleration, 0, len(taints))
+	for _, taint := range taints {
+		tolerations = append(tolerations, corev1.Toleration{
+			Key:      taint.Key,
+			Operator: corev1.TolerationOpEqual,
+			Value:    taint.Value,
+			Effect:   taint.Effect,
+		})
+	}
+	return tolerations
+}
+
+// TaintOwnedKey returns the key of the taint recorded in the tunnel owned taints annotation.
+func TaintOwnedKey(taint corev1.Taint) string {
+	return taint.Key + ":" + string(taint.Effect)
+}
+
+// FormatOwnedKeys formats the owned keys as the value of the tunnel owned annotations.
+func FormatOwnedKeys(keys []string) string {
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// ParseOwnedKeys parses the value of the tunnel owned annotations into a set of keys.
+func ParseOwnedKeys(value string) map[string]bool {
+	ret := make(map[string]bool)
+	for _, key := range strings.Split(value, ",") {
+		if key != "" {
+			ret[key] = true
 		}
 	}
+	return ret
+}
 
-	return &ret, nil
+// ConvertBizStatusToContainerStatus converts tunnel container status to Kubernetes container status with the default status mapper, if not the status for the container, then create a empty state container status
+func ConvertBizStatusToContainerStatus(container *corev1.Container, containerStatus *corev1.ContainerStatus, data *model.BizStatusData) (*corev1.ContainerStatus, error) {
+	return NewDefaultStatusMapper().MapBizStatus(container, containerStatus, data)
 }
 
 // SplitMetaNamespaceKey splits a key into namespace and name.
@@ -372,13 +371,13 @@ func GetBizNameAndVersionFromUniqueKey(bizUniqueKey string) (string, string) {
 	return "", ""
 }
 
-func FillPodKey(pods []corev1.Pod, bizStatusDatas []model.BizStatusData) (toUpdate []model.BizStatusData, toDelete []model.BizStatusData) {
+func FillPodKey(pods []corev1.Pod, bizStatusDatas []model.BizStatusData, bizContainerMatcher model.BizContainerMatcher, bizKeyStrategy model.BizKeyStrategy) (toUpdate []model.BizStatusData, toDelete []model.BizStatusData) {
 	bizKeyToPodKey := make(map[string]string)
 	// 一个 vnode 上,  所有的 biz container name 是唯一的
 	for _, pod := range pods {
 		for _, container := range pod.Spec.Containers {
-			if strings.Contains(container.Image, ".jar") {
-				bizKeyToPodKey[GetBizUniqueKey(&container)] = GetPodKey(&pod)
+			if bizContainerMatcher.IsBizContainer(&pod, &container) {
+				bizKeyToPodKey[bizKeyStrategy.BizUniqueKey(&pod, &container)] = GetPodKey(&pod)
 			}
 		}
 	}
@@ -413,6 +412,48 @@ func ConvertNodeToNodeInfo(node *corev1.Node) model.NodeInfo {
 		}
 	}
 
+	customLabels := node.Labels
+	customAnnotations := node.Annotations
+	customTaints := node.Spec.Taints
+	if ownedLabels, has := node.Annotations[model.AnnotationKeyOfTunnelOwnedLabels]; has {
+		customLabels = make(map[string]string)
+		for key := range ParseOwnedKeys(ownedLabels) {
+			if value, ok := node.Labels[key]; ok {
+				customLabels[key] = value
+			}
+		}
+	}
+	if ownedAnnotations, has := node.Annotations[model.AnnotationKeyOfTunnelOwnedAnnotations]; has {
+		customAnnotations = make(map[string]string)
+		for key := range ParseOwnedKeys(ownedAnnotations) {
+			if value, ok := node.Annotations[key]; ok {
+				customAnnotations[key] = value
+			}
+		}
+	}
+	if ownedTaints, has := node.Annotations[model.AnnotationKeyOfTunnelOwnedTaints]; has {
+		owned := ParseOwnedKeys(ownedTaints)
+		customTaints = nil
+		for _, taint := range node.Spec.Taints {
+			if owned[TaintOwnedKey(taint)] {
+				customTaints = append(customTaints, taint)
+			}
+		}
+	}
+
+	systemInfo := model.SystemInfo{
+		Arch:             node.Status.NodeInfo.Architecture,
+		OS:               node.Status.NodeInfo.OperatingSystem,
+		RuntimeVersion:   node.Annotations[model.AnnotationKeyOfBaseRuntimeVersion],
+		FrameworkVersion: node.Annotations[model.AnnotationKeyOfBaseFrameworkVersion],
+		CPU:              node.Status.Capacity[corev1.ResourceCPU],
+		Memory:           node.Status.Capacity[corev1.ResourceMemory],
+	}
+	if bizSlots, has := node.Status.Capacity[model.ResourceNameBizSlots]; has {
+		maxBizSlots := bizSlots.Value()
+		systemInfo.MaxBizSlots = &maxBizSlots
+	}
+
 	return model.NodeInfo{
 		Metadata: model.NodeMetadata{
 			Name:        node.Name,
@@ -424,9 +465,10 @@ func ConvertNodeToNodeInfo(node *corev1.Node) model.NodeInfo {
 			NodeIP:   nodeIP,
 			HostName: nodeHostname,
 		},
-		CustomLabels:      node.Labels,
-		CustomAnnotations: node.Annotations,
-		CustomTaints:      node.Spec.Taints,
+		SystemInfo:        systemInfo,
+		CustomLabels:      customLabels,
+		CustomAnnotations: customAnnotations,
+		CustomTaints:      customTaints,
 		State:             model.NodeStateActivated,
 	}
 }
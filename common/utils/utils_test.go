@@ -340,7 +340,7 @@ func TestFillPodKey(t *testing.T) {
 		},
 	}
 
-	bizStatusDatasWithPodKey, bizStatusDatasWithNoPodKey := FillPodKey(pods, bizStatusDatas)
+	bizStatusDatasWithPodKey, bizStatusDatasWithNoPodKey := FillPodKey(pods, bizStatusDatas, DefaultBizContainerMatcher, DefaultBizKeyStrategy)
 	assert.Equal(t, "ut-ns/ut-pod1", bizStatusDatasWithPodKey[0].PodKey)
 	assert.Equal(t, "ut-ns/ut-pod2", bizStatusDatasWithPodKey[1].PodKey)
 	assert.Equal(t, len(bizStatusDatasWithNoPodKey), 0)
@@ -353,3 +353,31 @@ func TestOrElse(t *testing.T) {
 	assert.EqualValues(t, ptr.To(1), OrElse(nil, ptr.To(1)))
 	assert.Equal(t, "value", OrElse("value", "default"))
 }
+
+func TestSetNodeCondition(t *testing.T) {
+	node := &corev1.Node{
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+	SetNodeCondition(node, corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionFalse})
+	SetNodeCondition(node, corev1.NodeCondition{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse})
+	assert.Len(t, node.Status.Conditions, 2)
+	assert.Equal(t, corev1.ConditionFalse, node.Status.Conditions[0].Status)
+}
+
+func TestAddOrUpdateAndRemoveTaint(t *testing.T) {
+	taints := []corev1.Taint{
+		{Key: "a", Value: "1", Effect: corev1.TaintEffectNoExecute},
+	}
+	taints = AddOrUpdateTaint(taints, corev1.Taint{Key: "b", Value: "1", Effect: corev1.TaintEffectNoSchedule})
+	taints = AddOrUpdateTaint(taints, corev1.Taint{Key: "b", Value: "2", Effect: corev1.TaintEffectNoSchedule})
+	assert.Len(t, taints, 2)
+	assert.Equal(t, "2", taints[1].Value)
+
+	taints = RemoveTaint(taints, "b")
+	assert.Len(t, taints, 1)
+	assert.Equal(t, "a", taints[0].Key)
+}
@@ -14,6 +14,8 @@
 
 package model
 
+import v1 "k8s.io/api/core/v1"
+
 // VNodePrefix is a constant string used to prefix vnode names.
 const (
 	VNodePrefix = "vnode"
@@ -30,6 +32,7 @@ const (
 	TrackEventContainerShutdown = "ContainerShutdown" // Represents the event of a container shutting down.
 	TrackEventVPodDelete        = "PodDelete"         // Represents the event of a vPod being deleted.
 	TrackEventVPodUpdate        = "PodUpdate"         // Represents the event of a vPod being updated.
+	TrackEventBizUpgrade        = "BizUpgrade"        // Represents the event of a biz being hot upgraded to a new version.
 )
 
 const (
@@ -51,13 +54,106 @@ const (
 	LabelKeyOfBaseContainerName = "base.koupleless.io/container-name"
 )
 
+const (
+	// AnnotationKeyOfBaseName is a constant string used as a key for the base name a vpod should be scheduled to.
+	AnnotationKeyOfBaseName = "vpod.koupleless.io/base-name"
+	// AnnotationKeyOfBaseVersion is a constant string used as a key for the base version a vpod should be scheduled to.
+	AnnotationKeyOfBaseVersion = "vpod.koupleless.io/base-version"
+	// AnnotationKeyOfBaseClusterName is a constant string used as a key for the base cluster name a vpod should be scheduled to.
+	AnnotationKeyOfBaseClusterName = "vpod.koupleless.io/base-cluster-name"
+	// AnnotationKeyOfStartTimeoutSeconds is a constant string used as a key for the seconds the biz of a vpod should be activated in after started, non-positive means no timeout.
+	AnnotationKeyOfStartTimeoutSeconds = "vpod.koupleless.io/start-timeout-seconds"
+	// AnnotationKeyOfUpdateStrategy is a constant string used as a key for the strategy of in-place updates of the biz of a vpod, StopFirst by default.
+	AnnotationKeyOfUpdateStrategy = "vpod.koupleless.io/update-strategy"
+	// AnnotationKeyOfSuspended is a constant string used as a key for suspending a vpod, the biz of the vpod is deactivated but kept installed if the value is "true".
+	AnnotationKeyOfSuspended = "vpod.koupleless.io/suspended"
+	// AnnotationKeyOfRestartGeneration is a constant string used as a key for restarting a vpod in place, all biz of the vpod is reinstalled once the value changes.
+	AnnotationKeyOfRestartGeneration = "vpod.koupleless.io/restart-generation"
+)
+
+const (
+	// UpdateStrategyStopFirst stops the old biz before starting the new version, there is a serving gap.
+	UpdateStrategyStopFirst = "StopFirst"
+	// UpdateStrategyStartFirst starts the new version side by side, switches the traffic once it is ready and then stops the old biz.
+	// It falls back to StopFirst if the tunnel can't switch the traffic, or the new version keeps the biz key of the old one.
+	UpdateStrategyStartFirst = "StartFirst"
+)
+
+const (
+	// AnnotationKeyOfBaseRuntimeVersion is a constant string used as a key for the version of the language runtime of the base on vnode.
+	AnnotationKeyOfBaseRuntimeVersion = "base.koupleless.io/runtime-version"
+	// AnnotationKeyOfBaseFrameworkVersion is a constant string used as a key for the version of the base framework on vnode.
+	AnnotationKeyOfBaseFrameworkVersion = "base.koupleless.io/framework-version"
+	// AnnotationKeyOfTunnelOwnedLabels is a constant string used as a key for the custom label keys set by the tunnel on vnode.
+	AnnotationKeyOfTunnelOwnedLabels = "virtual-kubelet.koupleless.io/tunnel-owned-labels"
+	// AnnotationKeyOfTunnelOwnedAnnotations is a constant string used as a key for the custom annotation keys set by the tunnel on vnode.
+	AnnotationKeyOfTunnelOwnedAnnotations = "virtual-kubelet.koupleless.io/tunnel-owned-annotations"
+	// AnnotationKeyOfTunnelOwnedTaints is a constant string used as a key for the custom taints set by the tunnel on vnode, formatted as key:effect.
+	AnnotationKeyOfTunnelOwnedTaints = "virtual-kubelet.koupleless.io/tunnel-owned-taints"
+)
+
 const (
 	// TaintKeyOfVnode is a constant string used as a key for taints related to virtual nodes in Kubernetes objects.
 	TaintKeyOfVnode = "schedule.koupleless.io/virtual-node"
 	// TaintKeyOfEnv is a constant string used as a key for taints related to node environments in Kubernetes objects.
 	TaintKeyOfEnv = "schedule.koupleless.io/node-env"
+	// TaintKeyOfDeactivated is a constant string used as a key for taints of vnodes whose base is deactivated.
+	TaintKeyOfDeactivated = "schedule.koupleless.io/deactivated"
 )
 
+const (
+	// NodeReasonBaseDeactivated is the reason of vnode Ready condition when the base is deactivated.
+	NodeReasonBaseDeactivated = "BaseDeactivated"
+	// NodeReasonBaseUnreachable is the reason of vnode Ready condition when the base stops posting heart beat, same as kubelet.
+	NodeReasonBaseUnreachable = "NodeStatusUnknown"
+	// NodeReasonBaseDead is the reason of vnode Ready condition when the base is dead and the vnode is kept.
+	NodeReasonBaseDead = "BaseDead"
+)
+
+const (
+	// ContainerReasonBizBroken is the waiting reason of biz containers which are broken, the reason reported by the tunnel is kept in the last termination state.
+	ContainerReasonBizBroken = "BizBroken"
+	// ContainerReasonBizDeactivated is the default waiting reason of biz containers which are deactivated.
+	ContainerReasonBizDeactivated = "BizDeactivated"
+	// ContainerReasonBizError is the default termination reason of biz containers which are broken.
+	ContainerReasonBizError = "Error"
+	// ContainerReasonBizCompleted is the default termination reason of biz containers which are stopped.
+	ContainerReasonBizCompleted = "Completed"
+	// ContainerReasonCrashLoopBackOff is the waiting reason of broken biz containers waiting to be reinstalled, same as kubelet.
+	ContainerReasonCrashLoopBackOff = "CrashLoopBackOff"
+	// ContainerReasonContainerCreating is the waiting reason of broken or restarted biz containers being reinstalled, same as kubelet.
+	ContainerReasonContainerCreating = "ContainerCreating"
+	// ContainerReasonStartTimeout is the reason of biz containers not activated before the start deadline.
+	ContainerReasonStartTimeout = "StartTimeout"
+	// ContainerReasonFailedPostStartHook is the reason of biz containers whose postStart hook failed, same as the event reason of kubelet.
+	ContainerReasonFailedPostStartHook = "FailedPostStartHook"
+	// ContainerReasonCreateContainerConfigError is the waiting reason of biz containers whose env references can't be resolved, same as kubelet.
+	ContainerReasonCreateContainerConfigError = "CreateContainerConfigError"
+)
+
+const (
+	// PodReasonOutOfMemory is the reason of vpods rejected because the base has no enough memory, same as kubelet.
+	PodReasonOutOfMemory = "OutOfmemory"
+	// PodReasonOutOfBizSlots is the reason of vpods rejected because the base has no free biz slots.
+	PodReasonOutOfBizSlots = "OutOfbizslots"
+	// PodReasonSuspended is the reason of the Ready condition of suspended vpods, whose biz is deactivated and the vpod keeps running.
+	PodReasonSuspended = "Suspended"
+)
+
+const (
+	// PodConditionTypeBizUpdating is the type of the vpod condition recording the in-place update in flight, its reason is the phase
+	// and its message records the strategy and the changed containers, the update interrupted by a restart of virtual kubelet is resumed from it.
+	PodConditionTypeBizUpdating = "vpod.koupleless.io/BizUpdating"
+)
+
+const (
+	// PodEventReasonBizUpgradeRolledBack is the reason of the event when the new version of a StartFirst update fails to come up and the old biz keeps serving.
+	PodEventReasonBizUpgradeRolledBack = "BizUpgradeRolledBack"
+)
+
+// ResourceNameBizSlots is the extended resource of the count of biz modules a vnode can host, each biz container takes one slot by default.
+const ResourceNameBizSlots v1.ResourceName = "koupleless.io/biz"
+
 const (
 	// ComponentVNode is a constant string used to identify the vnode component in the system.
 	ComponentVNode = "vnode"
@@ -67,13 +163,15 @@ const (
 
 type ErrorCode string
 
-// CodeSuccess, CodeTimeout, CodeContainerStartTimeout, CodeContainerStartFailed, and CodeContainerStopFailed are constant ErrorCode values representing different error scenarios.
+// CodeSuccess, CodeTimeout, CodeContainerStartTimeout, CodeContainerStartFailed, CodeContainerStopFailed, CodeBizUpgradeFailed, and CodeContainerConfigError are constant ErrorCode values representing different error scenarios.
 const (
 	CodeSuccess               ErrorCode = "00000"
 	CodeTimeout               ErrorCode = "00001"
 	CodeContainerStartTimeout ErrorCode = "00002"
 	CodeContainerStartFailed  ErrorCode = "01002"
 	CodeContainerStopFailed   ErrorCode = "01003"
+	CodeBizUpgradeFailed      ErrorCode = "01004"
+	CodeContainerConfigError  ErrorCode = "01005"
 )
 
 // NodeState is the node curr status
@@ -126,6 +224,18 @@ const (
 	NodeToFetchAllBizStatusInterval = 15
 	// NodeToCheckUnreachableAndDeadStatusInterval is the interval to check if node status is unreachable or dead
 	NodeToCheckUnreachableAndDeadStatusInterval = 3
+
+	// BizRestartBackOffInitialSeconds is the initial backoff of reinstalling a broken biz, doubled on each restart, same as kubelet
+	BizRestartBackOffInitialSeconds = 10
+	// BizRestartBackOffMaxSeconds is the maximum backoff of reinstalling a broken biz, the backoff is reset after the biz runs for twice of it
+	BizRestartBackOffMaxSeconds = 300
+
+	// NodeDefaultMaxPods is the default pods capacity of a vnode whose base doesn't report max biz slots
+	NodeDefaultMaxPods = 65535
+	// NodeDeactivatedGracePeriodSeconds is the default grace period to drain a deactivated vnode before removing it
+	NodeDeactivatedGracePeriodSeconds = 30
+	// DeadVNodeRetentionSeconds is the default time the Node of a dead vnode is kept as NotReady before it is deleted
+	DeadVNodeRetentionSeconds = 3600
 )
 
 const (
@@ -9,6 +9,28 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// BizContainerMatcher decides which containers of a vpod are biz modules, only the status of biz containers is reflected in pod status
+type BizContainerMatcher interface {
+	// IsBizContainer returns whether the container of the pod is a biz module
+	IsBizContainer(pod *v1.Pod, container *v1.Container) bool
+}
+
+// BizKeyStrategy defines the unique key of biz modules, vnode matches the biz status reported by the tunnel to vpod containers with the key.
+// The tunnel can own the strategy by implementing this interface, otherwise it is set in controller config.
+type BizKeyStrategy interface {
+	// BizUniqueKey returns the unique key of the biz container in the pod, must be the same as BizStatusData.Key reported by the tunnel
+	BizUniqueKey(pod *v1.Pod, container *v1.Container) string
+	// ParseBizUniqueKey restores the biz container from the unique key, used to uninstall the biz whose vpod doesn't exist in k8s
+	ParseBizUniqueKey(key string) (*v1.Container, error)
+}
+
+// StatusMapper maps the biz status reported by the tunnel to the container status of vpods.
+// The tunnel can own the mapper by implementing this interface, otherwise it is set in controller config.
+type StatusMapper interface {
+	// MapBizStatus returns the container status from the previous status and the biz status data, previous and data may be nil
+	MapBizStatus(container *v1.Container, previous *v1.ContainerStatus, data *BizStatusData) (*v1.ContainerStatus, error)
+}
+
 // NetworkInfo is the network of vnode, will be set into node addresses
 type NetworkInfo struct {
 	NodeIP   string // IP address of the node
@@ -23,10 +45,22 @@ type NodeMetadata struct {
 	ClusterName string // ClusterName of the cluster the vnode belongs to
 }
 
+// SystemInfo is the system info of the base, will be transfer to node info and capacity of a vnode
+type SystemInfo struct {
+	Arch             string            // Architecture of the base, e.g. amd64
+	OS               string            // Operating system of the base, e.g. linux
+	RuntimeVersion   string            // Version of the language runtime of the base, e.g. the jdk version
+	FrameworkVersion string            // Version of the base framework which runs the biz modules
+	CPU              resource.Quantity // Cpu capacity of the base
+	Memory           resource.Quantity // Memory capacity of the base
+	MaxBizSlots      *int64            // Max count of biz modules the base can host, advertised as ResourceNameBizSlots, nil means unlimited
+}
+
 // NodeInfo is the data of node info.
 type NodeInfo struct {
 	Metadata          NodeMetadata      // Metadata of the node
 	NetworkInfo       NetworkInfo       // Network information of the node
+	SystemInfo        SystemInfo        // System information of the base
 	CustomTaints      []v1.Taint        // Custom taints set by the tunnel
 	CustomLabels      map[string]string // Custom labels set by the tunnel
 	CustomAnnotations map[string]string // Custom annotations set by the tunnel
@@ -44,35 +78,43 @@ type NodeStatusData struct {
 	Resources        map[v1.ResourceName]NodeResource // Resources of the node
 	CustomConditions []v1.NodeCondition               // Custom conditions set by the tunnel
 	NodeState        NodeState                        // Current state of the vnode
+	SystemInfo       *SystemInfo                      // System information of the base, nil means unchanged
 }
 
 // BizStatusData is the status data of a container
 type BizStatusData struct {
-	Key        string    // Key generated by tunnel, must be the same as Tunnel GetBizUniqueKey of same container
+	Key        string    // Key generated by tunnel, must be the same as BizKeyStrategy BizUniqueKey of same container
 	Name       string    // Container name
 	PodKey     string    // Key of pod which contains this container ,you can set it to PodKeyAll to present a shared container
 	State      string    // State of the biz
 	ChangeTime time.Time // Time of state change
 	Reason     string    // Reason for state change
 	Message    string    // Message for state change
+	ExitCode   int32     // Exit code of the biz when stopped or broken, 0 means the default of the state
 }
 
 type BuildVNodeConfig struct {
-	Client            client.Client     // Runtime client instance
-	KubeCache         cache.Cache       // Cache of kube resources
-	BaseIP            string            // IP of the base
-	BaseHostName      string            // Hostname of the base
-	NodeIP            string            // NodeIP of the node
-	NodeName          string            // NodeName of the node
-	NodeVersion       string            // NodeVersion of the node
-	BaseName          string            // BaseName of the base, which is the app name of base
-	VPodType          string            // VPodType of the node
-	ClusterName       string            // ClusterName of the node
-	Env               string            // Environment of the node
-	CustomTaints      []v1.Taint        // Custom taints set by the tunnel
-	CustomLabels      map[string]string // Custom labels set by the tunnel
-	CustomAnnotations map[string]string // Custom annotations set by the tunnel
-	WorkerNum         int               // Worker num, if num is 1, means execute Container events serially
+	Client              client.Client       // Runtime client instance
+	KubeCache           cache.Cache         // Cache of kube resources
+	KubeReader          client.Reader       // Uncached reader of kube resources, the ConfigMaps and Secrets referred by vpods are read with it, nil means Client
+	BaseIP              string              // IP of the base
+	BaseHostName        string              // Hostname of the base
+	NodeIP              string              // NodeIP of the node
+	NodeName            string              // NodeName of the node
+	NodeVersion         string              // NodeVersion of the node
+	BaseName            string              // BaseName of the base, which is the app name of base
+	VPodType            string              // VPodType of the node
+	ClusterName         string              // ClusterName of the node
+	Env                 string              // Environment of the node
+	CustomTaints        []v1.Taint          // Custom taints set by the tunnel
+	CustomLabels        map[string]string   // Custom labels set by the tunnel
+	CustomAnnotations   map[string]string   // Custom annotations set by the tunnel
+	SystemInfo          SystemInfo          // System information of the base
+	BizContainerMatcher BizContainerMatcher // Decides which containers are biz modules, nil means the default jar matcher
+	BizKeyStrategy      BizKeyStrategy      // Unique key of biz modules, nil means the default name:version key
+	StatusMapper        StatusMapper        // Maps biz status to container status, nil means the default mapper
+	BizStartTimeout     time.Duration       // Default deadline of a started biz to be activated, non-positive means no timeout, no timeout by default
+	WorkerNum           int                 // Worker num, if num is 1, means execute Container events serially
 }
 
 type BuildVNodeControllerConfig struct {
@@ -85,6 +127,17 @@ type BuildVNodeControllerConfig struct {
 	WorkloadMaxLevel int           // Maximum workload level
 	VNodeWorkerNum   int           // VNode container event processor worker num, default 1, means execute Container events serially
 	PseudoNodeIP     string        // Pseudo node IP, will be used as the node IP for vnodes.
+
+	BizContainerMatcher BizContainerMatcher // Decides which containers of vpods are biz modules, default utils.DefaultBizContainerMatcher
+	BizKeyStrategy      BizKeyStrategy      // Unique key of biz modules, default the tunnel if it implements BizKeyStrategy, otherwise utils.DefaultBizKeyStrategy
+	StatusMapper        StatusMapper        // Maps biz status to container status, default the tunnel if it implements StatusMapper, otherwise utils.NewDefaultStatusMapper
+
+	BizStartTimeout              time.Duration // Default deadline of a started biz to be activated, overridden by model.AnnotationKeyOfStartTimeoutSeconds of vpods, non-positive means no timeout, no timeout by default
+	VNodeDeactivationGracePeriod time.Duration // Grace period to drain a deactivated vnode before removing it, default model.NodeDeactivatedGracePeriodSeconds
+	EvictVPodsOnDeactivation     bool          // Whether to evict the vpods of a deactivated vnode, so their controllers reschedule them elsewhere
+	KeepDeadVNode                bool          // Whether to keep the Node of a dead vnode as NotReady instead of deleting it at once
+	DeadVNodeRetention           time.Duration // Time to keep the Node of a dead vnode when KeepDeadVNode is set before deleting it, default model.DeadVNodeRetentionSeconds
+	StopBizOnVNodeDeleted        bool          // Whether to stop the biz modules when the Node of a vnode is deleted by user, or leave them running
 }
 
 // QueryBaselineRequest is the request parameters of query baseline func
@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/koupleless/virtual-kubelet/model"
+	"github.com/koupleless/virtual-kubelet/tunnel"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newTestVPodProvider starts the tunnel with no-op callbacks and builds a vpod provider of node test-node on it,
+// a MockTunnel is used if tl is nil and the kube cache reads from kubeClient if set.
+// The pods notified by the provider are sent to the returned channel.
+func newTestVPodProvider(t *testing.T, tl tunnel.Tunnel, kubeClient client.Client) (*VPodProvider, chan *corev1.Pod) {
+	t.Helper()
+	if tl == nil {
+		tl = &tunnel.MockTunnel{}
+	}
+	assert.NoError(t, tl.Start("test", "test"))
+	tl.RegisterCallback(
+		func(info model.NodeInfo) {},
+		func(s string, data model.NodeStatusData) {},
+		func(s string, data []model.BizStatusData) {},
+		func(s string, data model.BizStatusData) {},
+	)
+
+	var provider *VPodProvider
+	if kubeClient != nil {
+		provider = NewVPodProvider("default", "127.0.0.1", "test-node", kubeClient, fakeCache{Client: kubeClient}, tl)
+	} else {
+		provider = NewVPodProvider("default", "127.0.0.1", "test-node", nil, nil, tl)
+	}
+	notified := make(chan *corev1.Pod, 100)
+	provider.notify = func(pod *corev1.Pod) {
+		notified <- pod
+	}
+	return provider, notified
+}
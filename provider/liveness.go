@@ -9,12 +9,17 @@ import (
 type Liveness struct {
 	// for fast close, we need to close manually, or we need to wait to timeout
 	isClose             bool
+	isDeactivated       bool
 	LatestHeartBeatTime time.Time
 }
 
-func (liveness *Liveness) UpdateHeartBeatTime() {
+// UpdateHeartBeatTime refresh the heart beat of an activated base, returns true if the base was deactivated before
+func (liveness *Liveness) UpdateHeartBeatTime() bool {
+	wasDeactivated := liveness.isDeactivated
 	liveness.isClose = false
+	liveness.isDeactivated = false
 	liveness.LatestHeartBeatTime = time.Now()
+	return wasDeactivated
 }
 
 // close by deactive message
@@ -29,6 +34,20 @@ func (liveness *Liveness) Close() {
 	liveness.LatestHeartBeatTime = time.Now()
 }
 
+// Deactivate closes the liveness because the base upload the deactive message, and marks the vnode draining until it is shutdown,
+// returns true if the base was not deactivated before
+func (liveness *Liveness) Deactivate() bool {
+	wasDeactivated := liveness.isDeactivated
+	liveness.isDeactivated = true
+	liveness.Close()
+	return !wasDeactivated
+}
+
+// IsDeactivated check the base is deactivated and the vnode is draining, the dead vnode is shutdown after the drain grace period
+func (liveness *Liveness) IsDeactivated() bool {
+	return liveness.isDeactivated
+}
+
 // unReachable check the node is unreachable
 func (liveness *Liveness) IsReachable() bool {
 	return !liveness.isClose && time.Since(liveness.LatestHeartBeatTime) <= model.NodeToUnreachableMaxSeconds*time.Second
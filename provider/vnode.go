@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/koupleless/virtual-kubelet/common/utils"
@@ -15,6 +16,7 @@ import (
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -42,14 +44,29 @@ type VNode struct {
 	WhenLeaderAcquiredByOthers chan struct{} // Channel for signaling the leader has changed
 	WhenLeaderAcquiredByMe     chan struct{}
 	done                       chan struct{} // Channel for signaling the node has exited
+	released                   chan struct{} // Channel for signaling the node is dropped without removing it from k8s
 
 	lease    *coordinationv1.Lease // Latest lease of the node
 	Liveness Liveness              // Liveness of the node from provider
 
+	stateLock           sync.Mutex            // guards syncedLivenessState and drainCondition, serializes the transitions of the node state
+	syncedLivenessState livenessState         // Liveness state latest reflected to the node conditions and taints
+	drainCondition      *corev1.NodeCondition // Ready condition set by Drain, nil if the vnode is not drained
+
 	TakeOvered bool  // take overed by current vnodeController
 	err        error // Error that caused the node to exit
 }
 
+// livenessState is the liveness of the base which is reflected to the node conditions and taints
+type livenessState string
+
+const (
+	livenessStateReachable   livenessState = "Reachable"
+	livenessStateUnreachable livenessState = "Unreachable"
+	livenessStateDead        livenessState = "Dead"
+	livenessStateDeactivated livenessState = "Deactivated"
+)
+
 func (vNode *VNode) GetNodeName() string {
 	return vNode.name
 }
@@ -123,6 +140,8 @@ func (vNode *VNode) Run(vnCtx context.Context, takeOverVnCtx context.Context, in
 		return err
 	}
 
+	vNode.syncUnschedulableToTunnel(takeOverVnCtx)
+
 	go func() {
 		select {
 		case <-vNode.Exit():
@@ -146,6 +165,23 @@ func (vNode *VNode) cleanUp() {
 	vNode.tunnel.UnRegisterNode(vNode.name)
 }
 
+// syncUnschedulableToTunnel passes the cordon state of the node to the tunnel, the node may be cordoned before taken over
+func (vNode *VNode) syncUnschedulableToTunnel(ctx context.Context) {
+	observer, ok := vNode.tunnel.(tunnel.NodeUnschedulableObserver)
+	if !ok {
+		return
+	}
+	node := &corev1.Node{}
+	err := vNode.kubeCache.Get(ctx, types.NamespacedName{Name: vNode.name}, node)
+	if err != nil {
+		log.G(ctx).WithError(err).Errorf("failed to get node %s to sync unschedulable", vNode.name)
+		return
+	}
+	if node.Spec.Unschedulable {
+		observer.OnNodeUnschedulableChanged(vNode.name, true)
+	}
+}
+
 func (vNode *VNode) checkNodeExistsInClient(vnCtx context.Context) (bool, error) {
 	vnode := &corev1.Node{}
 	err := vNode.kubeCache.Get(vnCtx, types.NamespacedName{
@@ -240,11 +276,19 @@ func (vNode *VNode) SyncNodeStatus(data model.NodeStatusData) {
 	}
 }
 
+// UpdateNodeInfo applies the node info reported by the tunnel to the running vnode, the node in k8s is only patched if syncToKube is set
+func (vNode *VNode) UpdateNodeInfo(ctx context.Context, info model.NodeInfo, syncToKube bool) error {
+	err := vNode.nodeProvider.UpdateNodeInfo(ctx, info, syncToKube)
+	vNode.podProvider.SetLocalIP(vNode.nodeProvider.BaseIP())
+	return err
+}
+
 // SyncBatchBizStatusToKube syncs the status of all containers
 func (vNode *VNode) SyncBatchBizStatusToKube(ctx context.Context, toUpdateInKube []model.BizStatusData, toDeleteInProvider []model.BizStatusData) {
 	if vNode.podProvider != nil {
 		vNode.podProvider.SyncAllBizStatusToKube(ctx, toUpdateInKube)
 		vNode.syncNotExistBizPodToProvider(ctx, toDeleteInProvider)
+		vNode.podProvider.AdvancePodUpdates(ctx, concatBizStatusDatas(toUpdateInKube, toDeleteInProvider), true)
 	}
 }
 
@@ -255,32 +299,221 @@ func (vNode *VNode) SyncOneNodeBizStatusToKube(ctx context.Context, toUpdateInKu
 			vNode.podProvider.SyncBizStatusToKube(ctx, bizStatus)
 		}
 		vNode.syncNotExistBizPodToProvider(ctx, toDeleteInProvider)
+		vNode.podProvider.AdvancePodUpdates(ctx, concatBizStatusDatas(toUpdateInKube, toDeleteInProvider), false)
 	}
 }
 
+// concatBizStatusDatas joins the biz status with and without pod, the old biz of updated pods has no pod any more
+func concatBizStatusDatas(withPodKey, withoutPodKey []model.BizStatusData) []model.BizStatusData {
+	ret := make([]model.BizStatusData, 0, len(withPodKey)+len(withoutPodKey))
+	ret = append(ret, withPodKey...)
+	return append(ret, withoutPodKey...)
+}
+
 func (vNode *VNode) syncNotExistBizPodToProvider(ctx context.Context, toDeleteInProvider []model.BizStatusData) {
 	for _, bizStatus := range toDeleteInProvider {
-		bizName, bizVersion := utils.GetBizNameAndVersionFromUniqueKey(bizStatus.Key)
-		err := vNode.podProvider.DeletePod(ctx, &corev1.Pod{
+		err := vNode.podProvider.StopOrphanBiz(ctx, bizStatus.Key)
+		if err != nil {
+			log.G(ctx).WithError(err).Errorf("Failed to stop biz %s without pod", bizStatus.Key)
+		}
+	}
+}
+
+// Drain takes the vnode out of scheduling when the base is deactivated: the Ready condition turns to false with the reason,
+// a NoSchedule taint is added, and the vpods are evicted so their controllers reschedule them elsewhere if evictVPods is true.
+func (vNode *VNode) Drain(ctx context.Context, reason, message string, evictVPods bool) error {
+	if vNode.nodeProvider == nil {
+		return errors.Errorf("node provider of vnode %s not initialized", vNode.name)
+	}
+
+	now := metav1.Now()
+	vNode.stateLock.Lock()
+	vNode.drainCondition = &corev1.NodeCondition{
+		Type:               corev1.NodeReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            message,
+	}
+	vNode.nodeProvider.SetReadyCondition(vNode.drainCondition)
+
+	err := vNode.nodeProvider.UpdateNodeTaints(ctx, func(taints []corev1.Taint) []corev1.Taint {
+		return utils.AddOrUpdateTaint(taints, corev1.Taint{
+			Key:       model.TaintKeyOfDeactivated,
+			Value:     "True",
+			Effect:    corev1.TaintEffectNoSchedule,
+			TimeAdded: &now,
+		})
+	})
+	vNode.stateLock.Unlock()
+	if err != nil {
+		log.G(ctx).WithError(err).Errorf("failed to taint deactivated vnode %s", vNode.name)
+		return err
+	}
+
+	vNode.tunnel.OnNodeNotReady(vNode.name)
+
+	if evictVPods {
+		vNode.EvictVPods(ctx)
+	}
+	return nil
+}
+
+// UnDrain restores the vnode drained by Drain when the base is activated again.
+func (vNode *VNode) UnDrain(ctx context.Context) error {
+	if vNode.nodeProvider == nil {
+		return errors.Errorf("node provider of vnode %s not initialized", vNode.name)
+	}
+
+	vNode.stateLock.Lock()
+	defer vNode.stateLock.Unlock()
+	vNode.drainCondition = nil
+	state := vNode.livenessState()
+	vNode.nodeProvider.SetReadyCondition(vNode.readyConditionOf(state, metav1.Now()))
+
+	err := vNode.nodeProvider.UpdateNodeTaints(ctx, func(taints []corev1.Taint) []corev1.Taint {
+		return utils.RemoveTaint(taints, model.TaintKeyOfDeactivated)
+	})
+	if err != nil {
+		log.G(ctx).WithError(err).Errorf("failed to remove deactivated taint of vnode %s", vNode.name)
+	}
+	return err
+}
+
+// SyncLivenessToNode reflects the liveness of the base to the vnode the same way as the node lifecycle controller does for kubelets:
+// an unreachable vnode reports Ready=Unknown with unreachable taints, and a dead vnode which is kept reports Ready=False with not-ready taints.
+// A deactivated vnode keeps the condition and taint set by Drain until it is shutdown.
+func (vNode *VNode) SyncLivenessToNode(ctx context.Context) error {
+	if vNode.nodeProvider == nil {
+		return errors.Errorf("node provider of vnode %s not initialized", vNode.name)
+	}
+
+	vNode.stateLock.Lock()
+	state := vNode.livenessState()
+	if state == vNode.syncedLivenessState || (vNode.syncedLivenessState == "" && state == livenessStateReachable) {
+		vNode.stateLock.Unlock()
+		return nil
+	}
+	log.G(ctx).Infof("vnode %s turns from %s to %s", vNode.name, utils.OrElse(vNode.syncedLivenessState, livenessStateReachable), state)
+
+	now := metav1.Now()
+	vNode.nodeProvider.SetReadyCondition(vNode.readyConditionOf(state, now))
+	err := vNode.nodeProvider.UpdateNodeTaints(ctx, func(taints []corev1.Taint) []corev1.Taint {
+		switch state {
+		case livenessStateUnreachable:
+			taints = utils.RemoveTaint(taints, corev1.TaintNodeNotReady)
+			return addTaintsOfKey(taints, corev1.TaintNodeUnreachable, &now)
+		case livenessStateDead:
+			taints = utils.RemoveTaint(taints, corev1.TaintNodeUnreachable)
+			return addTaintsOfKey(taints, corev1.TaintNodeNotReady, &now)
+		default:
+			taints = utils.RemoveTaint(taints, corev1.TaintNodeUnreachable)
+			return utils.RemoveTaint(taints, corev1.TaintNodeNotReady)
+		}
+	})
+	if err == nil {
+		vNode.syncedLivenessState = state
+	}
+	vNode.stateLock.Unlock()
+
+	if state == livenessStateUnreachable {
+		vNode.tunnel.OnNodeNotReady(vNode.name)
+	}
+	if err != nil {
+		log.G(ctx).WithError(err).Errorf("failed to update taints of vnode %s to %s", vNode.name, state)
+		return err
+	}
+	return nil
+}
+
+// livenessState returns the current liveness state of the base
+func (vNode *VNode) livenessState() livenessState {
+	switch {
+	case vNode.Liveness.IsDeactivated():
+		return livenessStateDeactivated
+	case vNode.Liveness.IsDead():
+		return livenessStateDead
+	case !vNode.Liveness.IsReachable():
+		return livenessStateUnreachable
+	}
+	return livenessStateReachable
+}
+
+// readyConditionOf returns the Ready condition overriding the one reported by the tunnel in the liveness state, nil if not overridden,
+// must be called with stateLock held
+func (vNode *VNode) readyConditionOf(state livenessState, now metav1.Time) *corev1.NodeCondition {
+	switch state {
+	case livenessStateDeactivated:
+		return vNode.drainCondition
+	case livenessStateUnreachable:
+		return &corev1.NodeCondition{
+			Type:               corev1.NodeReady,
+			Status:             corev1.ConditionUnknown,
+			LastTransitionTime: now,
+			Reason:             model.NodeReasonBaseUnreachable,
+			Message:            "Base stopped posting heart beat.",
+		}
+	case livenessStateDead:
+		return &corev1.NodeCondition{
+			Type:               corev1.NodeReady,
+			Status:             corev1.ConditionFalse,
+			LastTransitionTime: now,
+			Reason:             model.NodeReasonBaseDead,
+			Message:            "Base is dead.",
+		}
+	}
+	return nil
+}
+
+// addTaintsOfKey adds NoSchedule and NoExecute taints of the key, same as the taints added by node lifecycle controller
+func addTaintsOfKey(taints []corev1.Taint, key string, timeAdded *metav1.Time) []corev1.Taint {
+	taints = utils.AddOrUpdateTaint(taints, corev1.Taint{
+		Key:    key,
+		Effect: corev1.TaintEffectNoSchedule,
+	})
+	return utils.AddOrUpdateTaint(taints, corev1.Taint{
+		Key:       key,
+		Effect:    corev1.TaintEffectNoExecute,
+		TimeAdded: timeAdded,
+	})
+}
+
+// EvictVPods evicts all vpods bound to the vnode through the eviction api, so PodDisruptionBudgets are respected.
+func (vNode *VNode) EvictVPods(ctx context.Context) {
+	podList := &corev1.PodList{}
+	err := vNode.client.List(ctx, podList,
+		client.MatchingFields{"spec.nodeName": vNode.name},
+		client.MatchingLabels{model.LabelKeyOfComponent: vNode.vpodType},
+	)
+	if err != nil {
+		log.G(ctx).WithError(err).Errorf("failed to list pods to evict on vnode %s", vNode.name)
+		return
+	}
+
+	for _, pod := range podList.Items {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		eviction := &policyv1.Eviction{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      model.ObjectMetaNameNotExistPod,
-				Namespace: corev1.NamespaceDefault,
-			},
-			Spec: corev1.PodSpec{
-				Containers: []corev1.Container{
-					{
-						Name: bizName,
-						Env: []corev1.EnvVar{{
-							Name:  "BIZ_VERSION",
-							Value: bizVersion,
-						}},
-					},
-				},
+				Name:      pod.Name,
+				Namespace: pod.Namespace,
 			},
-		})
-		if err != nil {
-			log.G(ctx).WithError(err).Error("Failed to delete not-exist-pod in provider")
 		}
+		err = vNode.client.SubResource("eviction").Create(ctx, &pod, eviction)
+		if err != nil && !apierrors.IsNotFound(err) {
+			// pod disruption budget may reject the eviction with TooManyRequests
+			log.G(ctx).WithError(err).Warnf("failed to evict pod %s on vnode %s", utils.GetPodKey(&pod), vNode.name)
+			continue
+		}
+		log.G(ctx).Infof("evicted pod %s on vnode %s", utils.GetPodKey(&pod), vNode.name)
+	}
+}
+
+// StopAllBiz stops the biz of all vpods on the vnode
+func (vNode *VNode) StopAllBiz(ctx context.Context) {
+	if vNode.podProvider != nil {
+		vNode.podProvider.StopAllBiz(ctx)
 	}
 }
 
@@ -289,6 +522,12 @@ func (vNode *VNode) Done() <-chan struct{} {
 	return vNode.done
 }
 
+// Released returns a channel that will be closed when the vnode is dropped from current controller,
+// the Node and lease of the vnode in k8s are kept untouched.
+func (vNode *VNode) Released() <-chan struct{} {
+	return vNode.released
+}
+
 func (vNode *VNode) Exit() <-chan struct{} {
 	return vNode.exit
 }
@@ -357,6 +596,15 @@ func (vNode *VNode) ToDone() {
 	}
 }
 
+// Release is the func of dropping a vnode not taken over by current controller, which only stops the local routines of the vnode
+func (vNode *VNode) Release() {
+	select {
+	case <-vNode.released:
+	default:
+		close(vNode.released)
+	}
+}
+
 // // CheckAndUpdatePodStatus checks and updates a pod in the node
 // func (vNode *VNode) CheckAndUpdatePodStatus(ctx context.Context, key string, pod *corev1.Pod) {
 //	if vNode.node != nil {
@@ -417,6 +665,24 @@ func NewVNode(config *model.BuildVNodeConfig, tunnel tunnel.Tunnel) (kn *VNode,
 			nodeProvider = NewVNodeProvider(config)
 			// Initialize pod provider with node namespace, IP, ID, client, and tunnel
 			podProvider = NewVPodProvider(cfg.Node.Namespace, config.BaseIP, config.NodeName, config.Client, config.KubeCache, tunnel)
+			if config.KubeReader != nil {
+				podProvider.SetKubeReader(config.KubeReader)
+			}
+			if config.BizContainerMatcher != nil {
+				podProvider.SetBizContainerMatcher(config.BizContainerMatcher)
+			}
+			if config.BizKeyStrategy != nil {
+				podProvider.SetBizKeyStrategy(config.BizKeyStrategy)
+			}
+			if config.StatusMapper != nil {
+				podProvider.SetStatusMapper(config.StatusMapper)
+			}
+			if config.BizStartTimeout != 0 {
+				podProvider.SetBizStartTimeout(config.BizStartTimeout)
+			}
+			if cfg.EventRecorder != nil {
+				podProvider.SetEventRecorder(cfg.EventRecorder)
+			}
 
 			if err != nil {
 				return nil, nil, err
@@ -426,10 +692,6 @@ func NewVNode(config *model.BuildVNodeConfig, tunnel tunnel.Tunnel) (kn *VNode,
 		},
 		// Function to configure the node
 		func(cfg *nodeutil2.NodeConfig) error {
-			// Set the node's architecture and operating system
-			cfg.Node.Status.NodeInfo.Architecture = runtime.GOARCH
-			cfg.Node.Status.NodeInfo.OperatingSystem = runtime.GOOS
-
 			// Set the number of workers based on configuration
 			cfg.NumWorkers = config.WorkerNum
 			return nil
@@ -458,6 +720,7 @@ func NewVNode(config *model.BuildVNodeConfig, tunnel tunnel.Tunnel) (kn *VNode,
 		exit:                       make(chan struct{}),
 		ready:                      make(chan struct{}),
 		done:                       make(chan struct{}),
+		released:                   make(chan struct{}),
 		WhenLeaderAcquiredByMe:     make(chan struct{}, 1),
 		WhenLeaderAcquiredByOthers: make(chan struct{}),
 		Liveness:                   Liveness{}, // a very old time
@@ -465,57 +728,12 @@ func NewVNode(config *model.BuildVNodeConfig, tunnel tunnel.Tunnel) (kn *VNode,
 }
 
 func buildNode(node *corev1.Node, config *model.BuildVNodeConfig) error {
-	oldLabels := node.Labels
-	if oldLabels == nil {
-		oldLabels = make(map[string]string)
-	}
-	oldLabels[model.LabelKeyOfBaseName] = config.BaseName
-	oldLabels[model.LabelKeyOfBaseClusterName] = config.ClusterName
-	oldLabels[model.LabelKeyOfComponent] = model.ComponentVNode
-	oldLabels[model.LabelKeyOfEnv] = config.Env
-	oldLabels[model.LabelKeyOfBaseVersion] = config.NodeVersion
-	oldLabels[corev1.LabelHostname] = config.BaseHostName
-	oldLabels[model.LabelKeyOfBaseHostName] = config.BaseHostName
-	for k, v := range config.CustomLabels {
-		oldLabels[k] = v
-	}
-	node.Labels = oldLabels
-
-	oldAnnotations := node.Annotations
-	if oldAnnotations == nil {
-		oldAnnotations = make(map[string]string)
-	}
-	for k, v := range config.CustomAnnotations {
-		oldAnnotations[k] = v
-	}
-	node.Annotations = oldAnnotations
-
-	node.Spec.Taints = append([]corev1.Taint{
-		{
-			Key:    model.TaintKeyOfVnode,
-			Value:  "True",
-			Effect: corev1.TaintEffectNoExecute,
-		},
-		{
-			Key:    model.TaintKeyOfEnv,
-			Value:  config.Env,
-			Effect: corev1.TaintEffectNoExecute,
-		},
-	}, config.CustomTaints...)
+	applyNodeMetadata(node, config)
 
 	// Set the node status.
 	node.Status = corev1.NodeStatus{
-		Phase: corev1.NodeRunning,
-		Addresses: []corev1.NodeAddress{
-			{
-				Type:    corev1.NodeInternalIP,
-				Address: utils.OrElse(config.NodeIP, config.BaseIP),
-			},
-			{
-				Type:    corev1.NodeHostName,
-				Address: config.BaseHostName, // FIXME: should we use a different hostname?
-			},
-		},
+		Phase:     corev1.NodeRunning,
+		Addresses: nodeAddresses(config),
 		Conditions: []corev1.NodeCondition{
 			{
 				Type:   corev1.NodeReady,
@@ -538,13 +756,148 @@ func buildNode(node *corev1.Node, config *model.BuildVNodeConfig) error {
 				Status: corev1.ConditionFalse,
 			},
 		},
-		Capacity: map[corev1.ResourceName]resource.Quantity{
-			corev1.ResourcePods: resource.MustParse("65535"),
-		},
-		Allocatable: map[corev1.ResourceName]resource.Quantity{
-			corev1.ResourcePods: resource.MustParse("65535"),
-		},
 	}
+	applyNodeSystemInfo(node, config.SystemInfo)
 
 	return nil
 }
+
+// applyNodeSystemInfo sets the node info and capacity of the vnode from the system info of the base.
+// Arch and os not reported by the base fall back to the vk process, pods capacity falls back to model.NodeDefaultMaxPods.
+// The runtime and framework versions are metadata of the vnode, see applyNodeMetadata.
+func applyNodeSystemInfo(node *corev1.Node, info model.SystemInfo) {
+	node.Status.NodeInfo.Architecture = utils.OrElse(info.Arch, runtime.GOARCH)
+	node.Status.NodeInfo.OperatingSystem = utils.OrElse(info.OS, runtime.GOOS)
+
+	if node.Status.Capacity == nil {
+		node.Status.Capacity = make(corev1.ResourceList)
+	}
+	if node.Status.Allocatable == nil {
+		node.Status.Allocatable = make(corev1.ResourceList)
+	}
+	resources := corev1.ResourceList{}
+	if !info.CPU.IsZero() {
+		resources[corev1.ResourceCPU] = info.CPU
+	}
+	if !info.Memory.IsZero() {
+		resources[corev1.ResourceMemory] = info.Memory
+	}
+	if info.MaxBizSlots != nil {
+		resources[corev1.ResourcePods] = *resource.NewQuantity(*info.MaxBizSlots, resource.DecimalSI)
+		resources[model.ResourceNameBizSlots] = *resource.NewQuantity(*info.MaxBizSlots, resource.DecimalSI)
+	} else {
+		resources[corev1.ResourcePods] = *resource.NewQuantity(model.NodeDefaultMaxPods, resource.DecimalSI)
+		delete(node.Status.Capacity, model.ResourceNameBizSlots)
+		delete(node.Status.Allocatable, model.ResourceNameBizSlots)
+	}
+	for name, quantity := range resources {
+		node.Status.Capacity[name] = quantity
+		node.Status.Allocatable[name] = quantity
+	}
+}
+
+// normalizeBizSlots keeps the biz slots of the vnode consistent, allocatable biz slots never exceed the capacity,
+// and the allocatable is dropped if the capacity is not reported.
+func normalizeBizSlots(node *corev1.Node) {
+	capacity, hasCapacity := node.Status.Capacity[model.ResourceNameBizSlots]
+	if !hasCapacity {
+		delete(node.Status.Allocatable, model.ResourceNameBizSlots)
+		return
+	}
+	allocatable, hasAllocatable := node.Status.Allocatable[model.ResourceNameBizSlots]
+	if !hasAllocatable || allocatable.Cmp(capacity) > 0 {
+		node.Status.Allocatable[model.ResourceNameBizSlots] = capacity.DeepCopy()
+	}
+}
+
+// applyNodeMetadata sets the labels, annotations and taints of the vnode from the config.
+// The custom keys set by the tunnel are recorded in annotations of the node, so the ones dropped by the tunnel can be removed later.
+func applyNodeMetadata(node *corev1.Node, config *model.BuildVNodeConfig) {
+	labels := node.Labels
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	annotations := node.Annotations
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	for key := range utils.ParseOwnedKeys(annotations[model.AnnotationKeyOfTunnelOwnedLabels]) {
+		if _, has := config.CustomLabels[key]; !has {
+			delete(labels, key)
+		}
+	}
+	labels[model.LabelKeyOfBaseName] = config.BaseName
+	labels[model.LabelKeyOfBaseClusterName] = config.ClusterName
+	labels[model.LabelKeyOfComponent] = model.ComponentVNode
+	labels[model.LabelKeyOfEnv] = config.Env
+	labels[model.LabelKeyOfBaseVersion] = config.NodeVersion
+	labels[corev1.LabelHostname] = config.BaseHostName
+	labels[model.LabelKeyOfBaseHostName] = config.BaseHostName
+	labels[corev1.LabelArchStable] = utils.OrElse(config.SystemInfo.Arch, runtime.GOARCH)
+	labels[corev1.LabelOSStable] = utils.OrElse(config.SystemInfo.OS, runtime.GOOS)
+	ownedLabels := make([]string, 0, len(config.CustomLabels))
+	for k, v := range config.CustomLabels {
+		labels[k] = v
+		ownedLabels = append(ownedLabels, k)
+	}
+	node.Labels = labels
+
+	for key := range utils.ParseOwnedKeys(annotations[model.AnnotationKeyOfTunnelOwnedAnnotations]) {
+		if _, has := config.CustomAnnotations[key]; !has {
+			delete(annotations, key)
+		}
+	}
+	setOrDeleteAnnotation(annotations, model.AnnotationKeyOfBaseRuntimeVersion, config.SystemInfo.RuntimeVersion)
+	setOrDeleteAnnotation(annotations, model.AnnotationKeyOfBaseFrameworkVersion, config.SystemInfo.FrameworkVersion)
+	ownedAnnotations := make([]string, 0, len(config.CustomAnnotations))
+	for k, v := range config.CustomAnnotations {
+		annotations[k] = v
+		ownedAnnotations = append(ownedAnnotations, k)
+	}
+
+	previousOwnedTaints := utils.ParseOwnedKeys(annotations[model.AnnotationKeyOfTunnelOwnedTaints])
+	taints := make([]corev1.Taint, 0, len(node.Spec.Taints)+len(config.CustomTaints)+2)
+	for _, taint := range node.Spec.Taints {
+		if !previousOwnedTaints[utils.TaintOwnedKey(taint)] {
+			taints = append(taints, taint)
+		}
+	}
+	for _, taint := range utils.VNodeTaints(config.Env) {
+		taints = utils.AddOrUpdateTaint(taints, taint)
+	}
+	ownedTaints := make([]string, 0, len(config.CustomTaints))
+	for _, taint := range config.CustomTaints {
+		taints = utils.AddOrUpdateTaint(taints, taint)
+		ownedTaints = append(ownedTaints, utils.TaintOwnedKey(taint))
+	}
+	node.Spec.Taints = taints
+
+	annotations[model.AnnotationKeyOfTunnelOwnedLabels] = utils.FormatOwnedKeys(ownedLabels)
+	annotations[model.AnnotationKeyOfTunnelOwnedAnnotations] = utils.FormatOwnedKeys(ownedAnnotations)
+	annotations[model.AnnotationKeyOfTunnelOwnedTaints] = utils.FormatOwnedKeys(ownedTaints)
+	node.Annotations = annotations
+}
+
+// setOrDeleteAnnotation sets the annotation to the value, or deletes it if the value is not reported.
+func setOrDeleteAnnotation(annotations map[string]string, key, value string) {
+	if value == "" {
+		delete(annotations, key)
+		return
+	}
+	annotations[key] = value
+}
+
+// nodeAddresses returns the addresses of the vnode from the config.
+func nodeAddresses(config *model.BuildVNodeConfig) []corev1.NodeAddress {
+	return []corev1.NodeAddress{
+		{
+			Type:    corev1.NodeInternalIP,
+			Address: utils.OrElse(config.NodeIP, config.BaseIP),
+		},
+		{
+			Type:    corev1.NodeHostName,
+			Address: config.BaseHostName, // FIXME: should we use a different hostname?
+		},
+	}
+}
@@ -20,6 +20,9 @@ import (
 	"github.com/koupleless/virtual-kubelet/virtual_kubelet/node"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sync"
 
 	"github.com/koupleless/virtual-kubelet/model"
@@ -36,12 +39,124 @@ type VNodeProvider struct {
 	nodeConfig *model.BuildVNodeConfig // Configuration for building a virtual node provider.
 
 	notify func(*corev1.Node) // Function to notify about node status changes.
+
+	latestStatusData model.NodeStatusData  // Latest node status data from the tunnel.
+	readyCondition   *corev1.NodeCondition // Overrides the Ready condition reported to k8s, nil means the node is ready.
+
+	lastSyncedNodeInfo *model.NodeInfo // Node info latest synced to k8s, nil if the node info is not synced by current controller.
 }
 
 // Notify updates the latest node status data and notifies about the change.
 func (v *VNodeProvider) Notify(data model.NodeStatusData) {
 	v.Lock()
 	defer v.Unlock()
+	v.latestStatusData = data
+	if data.SystemInfo != nil {
+		v.nodeConfig.SystemInfo = *data.SystemInfo
+	}
+	v.notifyLocked()
+}
+
+// SetReadyCondition overrides the Ready condition of the node with the given one, nil restores the Ready condition reported by the tunnel.
+func (v *VNodeProvider) SetReadyCondition(condition *corev1.NodeCondition) {
+	v.Lock()
+	defer v.Unlock()
+	v.readyCondition = condition
+	v.notifyLocked()
+}
+
+// UpdateNodeTaints applies the mutation to the taints of the node in k8s, taints are spec fields which can't be updated with the node status.
+func (v *VNodeProvider) UpdateNodeTaints(ctx context.Context, mutate func([]corev1.Taint) []corev1.Taint) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node := &corev1.Node{}
+		err := v.nodeConfig.Client.Get(ctx, types.NamespacedName{Name: v.nodeConfig.NodeName}, node)
+		if err != nil {
+			return err
+		}
+		nodeCopy := node.DeepCopy()
+		nodeCopy.Spec.Taints = mutate(nodeCopy.Spec.Taints)
+		return v.nodeConfig.Client.Patch(ctx, nodeCopy, client.MergeFromWithOptions(node, client.MergeFromWithOptimisticLock{}))
+	})
+}
+
+// BaseIP returns the latest ip of the base.
+func (v *VNodeProvider) BaseIP() string {
+	v.Lock()
+	defer v.Unlock()
+	return v.nodeConfig.BaseIP
+}
+
+// UpdateNodeInfo applies the node info reported by the tunnel to a running vnode, custom labels, annotations and taints dropped by the tunnel are removed from the node.
+// The node in k8s is only patched if syncToKube is set and the node info is changed since the latest sync, otherwise only the config is updated.
+func (v *VNodeProvider) UpdateNodeInfo(ctx context.Context, info model.NodeInfo, syncToKube bool) error {
+	v.Lock()
+	defer v.Unlock()
+
+	if !syncToKube {
+		// the node may be changed by the leader, sync it again once taken over
+		v.lastSyncedNodeInfo = nil
+	} else if v.lastSyncedNodeInfo != nil && reflect.DeepEqual(*v.lastSyncedNodeInfo, info) {
+		return nil
+	}
+
+	config := v.nodeConfig
+	config.NodeVersion = utils.OrElse(info.Metadata.Version, config.NodeVersion)
+	config.BaseName = utils.OrElse(info.Metadata.BaseName, config.BaseName)
+	config.ClusterName = utils.OrElse(info.Metadata.ClusterName, config.ClusterName)
+	config.CustomLabels = info.CustomLabels
+	config.CustomAnnotations = info.CustomAnnotations
+	config.CustomTaints = info.CustomTaints
+
+	statusChanged := false
+	if !reflect.DeepEqual(info.SystemInfo, model.SystemInfo{}) && !reflect.DeepEqual(info.SystemInfo, config.SystemInfo) {
+		config.SystemInfo = info.SystemInfo
+		statusChanged = true
+	}
+	if info.NetworkInfo.NodeIP != "" && info.NetworkInfo.NodeIP != config.BaseIP {
+		config.BaseIP = info.NetworkInfo.NodeIP
+		statusChanged = true
+	}
+	if info.NetworkInfo.HostName != "" && info.NetworkInfo.HostName != config.BaseHostName {
+		config.BaseHostName = info.NetworkInfo.HostName
+		statusChanged = true
+	}
+
+	if !syncToKube {
+		return nil
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node := &corev1.Node{}
+		err := config.Client.Get(ctx, types.NamespacedName{Name: config.NodeName}, node)
+		if err != nil {
+			return err
+		}
+		nodeCopy := node.DeepCopy()
+		applyNodeMetadata(nodeCopy, config)
+		if reflect.DeepEqual(node.Labels, nodeCopy.Labels) &&
+			reflect.DeepEqual(node.Annotations, nodeCopy.Annotations) &&
+			reflect.DeepEqual(node.Spec.Taints, nodeCopy.Spec.Taints) {
+			return nil
+		}
+		return config.Client.Patch(ctx, nodeCopy, client.MergeFromWithOptions(node, client.MergeFromWithOptimisticLock{}))
+	})
+	if err != nil {
+		return err
+	}
+	v.lastSyncedNodeInfo = &info
+
+	if statusChanged {
+		v.notifyLocked()
+	}
+	return nil
+}
+
+// notifyLocked merges the latest status data into the node and notifies about the change, the lock must be held.
+func (v *VNodeProvider) notifyLocked() {
+	if v.notify == nil {
+		// node controller not started yet
+		return
+	}
 	node := &corev1.Node{}
 	ctx := context.Background()
 	err := v.nodeConfig.KubeCache.Get(ctx, types.NamespacedName{Name: v.nodeConfig.NodeName}, node)
@@ -49,7 +164,15 @@ func (v *VNodeProvider) Notify(data model.NodeStatusData) {
 		log.G(ctx).WithError(err).Error("failed to get node when try to notify status update.")
 		return
 	}
-	vnodeCopy := utils.MergeNodeFromProvider(node, data)
+	node = node.DeepCopy()
+	applyNodeSystemInfo(node, v.nodeConfig.SystemInfo)
+	// resources reported in status data take precedence over the ones derived from system info
+	vnodeCopy := utils.MergeNodeFromProvider(node, v.latestStatusData)
+	normalizeBizSlots(vnodeCopy)
+	vnodeCopy.Status.Addresses = nodeAddresses(v.nodeConfig)
+	if v.readyCondition != nil {
+		utils.SetNodeCondition(vnodeCopy, *v.readyCondition)
+	}
 	v.notify(vnodeCopy)
 }
 
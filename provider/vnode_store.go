@@ -19,6 +19,7 @@ import (
 	"github.com/pkg/errors"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Summary:
@@ -29,28 +30,74 @@ import (
 type VNodeStore struct {
 	sync.RWMutex
 	nodeNameToVNode map[string]*VNode // A map from node ID to VNode
+
+	decommissionedNodeNameToLastSeen map[string]time.Time // A map from decommissioned node name to the latest time its base is seen
 }
 
 // NewVNodeStore creates a new instance of VNodeStore.
 func NewVNodeStore() *VNodeStore {
 	return &VNodeStore{
-		RWMutex:         sync.RWMutex{},
-		nodeNameToVNode: make(map[string]*VNode),
+		RWMutex:                          sync.RWMutex{},
+		nodeNameToVNode:                  make(map[string]*VNode),
+		decommissionedNodeNameToLastSeen: make(map[string]time.Time),
+	}
+}
+
+// DecommissionVNode marks the vnode decommissioned because its Node is deleted by user,
+// the vnode will not be recreated until the base re-announces itself.
+func (r *VNodeStore) DecommissionVNode(nodeName string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.decommissionedNodeNameToLastSeen[nodeName] = time.Now()
+}
+
+// LiftDecommission releases the decommission of the vnode because its Node is created again.
+// It returns true if the vnode was decommissioned.
+func (r *VNodeStore) LiftDecommission(nodeName string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	_, has := r.decommissionedNodeNameToLastSeen[nodeName]
+	delete(r.decommissionedNodeNameToLastSeen, nodeName)
+	return has
+}
+
+// CheckDecommissionedOnProviderArrived checks whether the vnode is still decommissioned when a message of its base arrived.
+// The decommission is released when the base re-announces itself, which means the base is deactivated or
+// keeps silent for model.NodeToDeadMaxSeconds before the message.
+func (r *VNodeStore) CheckDecommissionedOnProviderArrived(nodeName string, state model.NodeState) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	lastSeen, has := r.decommissionedNodeNameToLastSeen[nodeName]
+	if !has {
+		return false
 	}
+
+	if !strings.EqualFold(string(state), string(model.NodeStateActivated)) || time.Since(lastSeen) >= model.NodeToDeadMaxSeconds*time.Second {
+		delete(r.decommissionedNodeNameToLastSeen, nodeName)
+		return !strings.EqualFold(string(state), string(model.NodeStateActivated))
+	}
+
+	r.decommissionedNodeNameToLastSeen[nodeName] = time.Now()
+	return true
 }
 
 // UpdateNodeStateOnProviderArrived updates the latest message time for a given node ID.
-func (r *VNodeStore) UpdateNodeStateOnProviderArrived(nodeName string, state model.NodeState) {
+// It returns true if the node turns from activated to deactivated or the reverse.
+func (r *VNodeStore) UpdateNodeStateOnProviderArrived(nodeName string, state model.NodeState) bool {
 	r.Lock()
 	defer r.Unlock()
 
 	if vNode, has := r.nodeNameToVNode[nodeName]; has {
 		if strings.EqualFold(string(state), string(model.NodeStateActivated)) {
-			vNode.Liveness.UpdateHeartBeatTime()
+			return vNode.Liveness.UpdateHeartBeatTime()
 		} else {
-			vNode.Liveness.Close()
+			return vNode.Liveness.Deactivate()
 		}
 	}
+	return false
 }
 
 // NodeShutdown removes a node from the running node map.
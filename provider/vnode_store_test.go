@@ -78,3 +78,43 @@ func TestGetLeaseOutdatedVNodeName(t *testing.T) {
 	nameList := store.GetLeaseOutdatedVNodeNames(clientId)
 	assert.Assert(t, len(nameList) == 1)
 }
+
+func TestUpdateNodeStateOnProviderArrived(t *testing.T) {
+	store := NewVNodeStore()
+	assert.Assert(t, !store.UpdateNodeStateOnProviderArrived("suite", model.NodeStateDeactivated))
+
+	vNode := &VNode{}
+	store.AddVNode("suite", vNode)
+	assert.Assert(t, !store.UpdateNodeStateOnProviderArrived("suite", model.NodeStateActivated))
+	assert.Assert(t, store.UpdateNodeStateOnProviderArrived("suite", model.NodeStateDeactivated))
+	assert.Assert(t, vNode.Liveness.IsDeactivated())
+	assert.Assert(t, vNode.Liveness.IsDead())
+	assert.Assert(t, !vNode.Liveness.IsReachable())
+	assert.Assert(t, !store.UpdateNodeStateOnProviderArrived("suite", model.NodeStateDeactivated))
+	assert.Assert(t, store.UpdateNodeStateOnProviderArrived("suite", model.NodeStateActivated))
+	assert.Assert(t, !vNode.Liveness.IsDeactivated())
+	assert.Assert(t, vNode.Liveness.IsReachable())
+}
+
+func TestDecommissionVNode(t *testing.T) {
+	store := NewVNodeStore()
+	assert.Assert(t, !store.CheckDecommissionedOnProviderArrived("suite", model.NodeStateActivated))
+
+	store.DecommissionVNode("suite")
+	assert.Assert(t, store.CheckDecommissionedOnProviderArrived("suite", model.NodeStateActivated))
+
+	// base re-announces itself after keeping silent
+	store.decommissionedNodeNameToLastSeen["suite"] = time.Now().Add(-model.NodeToDeadMaxSeconds * time.Second)
+	assert.Assert(t, !store.CheckDecommissionedOnProviderArrived("suite", model.NodeStateActivated))
+
+	// base re-announces itself after deactivated
+	store.DecommissionVNode("suite")
+	assert.Assert(t, store.CheckDecommissionedOnProviderArrived("suite", model.NodeStateDeactivated))
+	assert.Assert(t, !store.CheckDecommissionedOnProviderArrived("suite", model.NodeStateActivated))
+
+	// node is created again
+	assert.Assert(t, !store.LiftDecommission("suite"))
+	store.DecommissionVNode("suite")
+	assert.Assert(t, store.LiftDecommission("suite"))
+	assert.Assert(t, !store.CheckDecommissionedOnProviderArrived("suite", model.NodeStateActivated))
+}
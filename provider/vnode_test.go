@@ -2,15 +2,24 @@ package provider
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/koupleless/virtual-kubelet/common/tracker"
+	"github.com/koupleless/virtual-kubelet/common/utils"
 	"github.com/koupleless/virtual-kubelet/model"
 	"github.com/koupleless/virtual-kubelet/tunnel"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
@@ -332,3 +341,290 @@ func TestVNode_SyncOneNodeBizStatusToKube_BizKeyParsing(t *testing.T) {
 		})
 	}
 }
+
+func TestVNode_DrainAndUnDrain(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "default",
+			Labels:    map[string]string{model.LabelKeyOfComponent: "suite"},
+		},
+		Spec: corev1.PodSpec{NodeName: "test-node"},
+	}
+	fakeClient := fake.NewClientBuilder().
+		WithObjects(node, pod).
+		WithIndex(&corev1.Pod{}, "spec.nodeName", func(obj client.Object) []string {
+			return []string{obj.(*corev1.Pod).Spec.NodeName}
+		}).
+		Build()
+
+	vNode := &VNode{
+		name:     "test-node",
+		client:   fakeClient,
+		vpodType: "suite",
+		tunnel:   &tunnel.MockTunnel{},
+		nodeProvider: NewVNodeProvider(&model.BuildVNodeConfig{
+			Client:   fakeClient,
+			NodeName: "test-node",
+		}),
+	}
+
+	ctx := context.Background()
+	err := vNode.Drain(ctx, model.NodeReasonBaseDeactivated, "base is deactivated", true)
+	assert.NoError(t, err)
+
+	nodeFromKube := &corev1.Node{}
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-node"}, nodeFromKube))
+	assert.Len(t, nodeFromKube.Spec.Taints, 1)
+	assert.Equal(t, model.TaintKeyOfDeactivated, nodeFromKube.Spec.Taints[0].Key)
+	assert.Equal(t, corev1.ConditionFalse, vNode.nodeProvider.readyCondition.Status)
+
+	err = fakeClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "test-pod"}, &corev1.Pod{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// the deactivated vnode keeps the condition set by drain
+	vNode.Liveness.Deactivate()
+	assert.NoError(t, vNode.SyncLivenessToNode(ctx))
+	assert.Equal(t, model.NodeReasonBaseDeactivated, vNode.nodeProvider.readyCondition.Reason)
+
+	vNode.Liveness.UpdateHeartBeatTime()
+	err = vNode.UnDrain(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-node"}, nodeFromKube))
+	assert.Len(t, nodeFromKube.Spec.Taints, 0)
+	assert.Nil(t, vNode.nodeProvider.readyCondition)
+
+	// the condition is recomputed from the liveness when undrained
+	assert.NoError(t, vNode.Drain(ctx, model.NodeReasonBaseDeactivated, "base is deactivated", false))
+	vNode.Liveness.LatestHeartBeatTime = time.Now().Add(-(model.NodeToUnreachableMaxSeconds + 1) * time.Second)
+	assert.NoError(t, vNode.UnDrain(ctx))
+	assert.Equal(t, corev1.ConditionUnknown, vNode.nodeProvider.readyCondition.Status)
+
+	// the tunnel callbacks and the liveness checks change the node state concurrently
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			_ = vNode.Drain(ctx, model.NodeReasonBaseDeactivated, "base is deactivated", false)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = vNode.UnDrain(ctx)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = vNode.SyncLivenessToNode(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestVNode_SyncLivenessToNode(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+	}
+	fakeClient := fake.NewClientBuilder().WithObjects(node).Build()
+	vNode := &VNode{
+		name:   "test-node",
+		client: fakeClient,
+		tunnel: &tunnel.MockTunnel{},
+		nodeProvider: NewVNodeProvider(&model.BuildVNodeConfig{
+			Client:   fakeClient,
+			NodeName: "test-node",
+		}),
+	}
+	ctx := context.Background()
+	getTaintKeys := func() []string {
+		nodeFromKube := &corev1.Node{}
+		assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-node"}, nodeFromKube))
+		keys := make([]string, 0)
+		for _, taint := range nodeFromKube.Spec.Taints {
+			keys = append(keys, taint.Key+":"+string(taint.Effect))
+		}
+		return keys
+	}
+
+	vNode.Liveness.UpdateHeartBeatTime()
+	assert.NoError(t, vNode.SyncLivenessToNode(ctx))
+	assert.Nil(t, vNode.nodeProvider.readyCondition)
+	assert.Len(t, getTaintKeys(), 0)
+
+	vNode.Liveness.LatestHeartBeatTime = time.Now().Add(-(model.NodeToUnreachableMaxSeconds + 1) * time.Second)
+	assert.NoError(t, vNode.SyncLivenessToNode(ctx))
+	assert.Equal(t, corev1.ConditionUnknown, vNode.nodeProvider.readyCondition.Status)
+	assert.ElementsMatch(t, []string{corev1.TaintNodeUnreachable + ":NoSchedule", corev1.TaintNodeUnreachable + ":NoExecute"}, getTaintKeys())
+
+	vNode.Liveness.LatestHeartBeatTime = time.Now().Add(-(model.NodeToDeadMaxSeconds + 1) * time.Second)
+	assert.NoError(t, vNode.SyncLivenessToNode(ctx))
+	assert.Equal(t, corev1.ConditionFalse, vNode.nodeProvider.readyCondition.Status)
+	assert.ElementsMatch(t, []string{corev1.TaintNodeNotReady + ":NoSchedule", corev1.TaintNodeNotReady + ":NoExecute"}, getTaintKeys())
+
+	vNode.Liveness.UpdateHeartBeatTime()
+	assert.NoError(t, vNode.SyncLivenessToNode(ctx))
+	assert.Nil(t, vNode.nodeProvider.readyCondition)
+	assert.Len(t, getTaintKeys(), 0)
+}
+
+func TestVNode_UpdateNodeInfo(t *testing.T) {
+	config := &model.BuildVNodeConfig{
+		NodeName:     "test-node",
+		BaseIP:       "127.0.0.1",
+		NodeVersion:  "1.0.0",
+		Env:          "test",
+		CustomLabels: map[string]string{"label-a": "a", "label-b": "b"},
+		CustomTaints: []corev1.Taint{{Key: "taint-a", Value: "a", Effect: corev1.TaintEffectNoSchedule}},
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}}
+	assert.NoError(t, buildNode(node, config))
+	node.Labels["user-label"] = "user"
+	node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{Key: "user-taint", Effect: corev1.TaintEffectNoSchedule})
+
+	fakeClient := fake.NewClientBuilder().WithObjects(node).Build()
+	config.Client = fakeClient
+	vNode := &VNode{
+		name:         "test-node",
+		client:       fakeClient,
+		nodeProvider: NewVNodeProvider(config),
+		podProvider:  NewVPodProvider("default", config.BaseIP, "test-node", fakeClient, nil, &tunnel.MockTunnel{}),
+	}
+
+	ctx := context.Background()
+	info := model.NodeInfo{
+		Metadata:     model.NodeMetadata{Name: "test-node", Version: "2.0.0"},
+		NetworkInfo:  model.NetworkInfo{NodeIP: "127.0.0.2"},
+		CustomLabels: map[string]string{"label-b": "b2"},
+		CustomTaints: []corev1.Taint{{Key: "taint-b", Value: "b", Effect: corev1.TaintEffectNoSchedule}},
+	}
+	err := vNode.UpdateNodeInfo(ctx, info, true)
+	assert.NoError(t, err)
+
+	nodeFromKube := &corev1.Node{}
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-node"}, nodeFromKube))
+	assert.Equal(t, "2.0.0", nodeFromKube.Labels[model.LabelKeyOfBaseVersion])
+	assert.NotContains(t, nodeFromKube.Labels, "label-a")
+	assert.Equal(t, "b2", nodeFromKube.Labels["label-b"])
+	assert.Equal(t, "user", nodeFromKube.Labels["user-label"])
+	taintKeys := make([]string, 0)
+	for _, taint := range nodeFromKube.Spec.Taints {
+		taintKeys = append(taintKeys, taint.Key)
+	}
+	assert.ElementsMatch(t, []string{model.TaintKeyOfVnode, model.TaintKeyOfEnv, "taint-b", "user-taint"}, taintKeys)
+	assert.Equal(t, "127.0.0.2", vNode.podProvider.localIP.Load())
+
+	nodeInfo := utils.ConvertNodeToNodeInfo(nodeFromKube)
+	assert.Equal(t, map[string]string{"label-b": "b2"}, nodeInfo.CustomLabels)
+	assert.Len(t, nodeInfo.CustomTaints, 1)
+
+	// the node is not patched again if the node info is not changed
+	nodeCopy := nodeFromKube.DeepCopy()
+	delete(nodeCopy.Labels, "label-b")
+	assert.NoError(t, fakeClient.Update(ctx, nodeCopy))
+	assert.NoError(t, vNode.UpdateNodeInfo(ctx, info, true))
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-node"}, nodeFromKube))
+	assert.NotContains(t, nodeFromKube.Labels, "label-b")
+
+	// the node is synced again after taken over
+	assert.NoError(t, vNode.UpdateNodeInfo(ctx, info, false))
+	assert.NoError(t, vNode.UpdateNodeInfo(ctx, info, true))
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-node"}, nodeFromKube))
+	assert.Equal(t, "b2", nodeFromKube.Labels["label-b"])
+}
+
+func TestVNodeProvider_SystemInfo(t *testing.T) {
+	config := &model.BuildVNodeConfig{
+		NodeName: "test-node",
+		SystemInfo: model.SystemInfo{
+			Arch:             "arm64",
+			OS:               "linux",
+			RuntimeVersion:   "17.0.1",
+			FrameworkVersion: "1.2.3",
+			CPU:              resource.MustParse("2"),
+			Memory:           resource.MustParse("4Gi"),
+			MaxBizSlots:      ptr.To(int64(8)),
+		},
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}}
+	assert.NoError(t, buildNode(node, config))
+	assert.Equal(t, "arm64", node.Status.NodeInfo.Architecture)
+	assert.Equal(t, "arm64", node.Labels[corev1.LabelArchStable])
+	assert.Equal(t, "1.2.3", node.Annotations[model.AnnotationKeyOfBaseFrameworkVersion])
+	assert.Equal(t, "17.0.1", node.Annotations[model.AnnotationKeyOfBaseRuntimeVersion])
+	assert.Empty(t, node.Status.NodeInfo.ContainerRuntimeVersion)
+	assert.Empty(t, node.Status.NodeInfo.KernelVersion)
+	assert.Equal(t, int64(8), node.Status.Capacity.Pods().Value())
+	assert.Equal(t, int64(8), node.Status.Allocatable.Pods().Value())
+	assert.Equal(t, int64(8), node.Status.Capacity.Name(model.ResourceNameBizSlots, resource.DecimalSI).Value())
+	assert.True(t, node.Status.Capacity.Memory().Equal(resource.MustParse("4Gi")))
+
+	fakeClient := fake.NewClientBuilder().WithObjects(node).Build()
+	config.Client = fakeClient
+	config.KubeCache = fakeCache{Client: fakeClient}
+	nodeProvider := NewVNodeProvider(config)
+	notified := make([]*corev1.Node, 0)
+	nodeProvider.notify = func(node *corev1.Node) {
+		notified = append(notified, node)
+	}
+	nodeProvider.Notify(model.NodeStatusData{
+		SystemInfo: &model.SystemInfo{Arch: "amd64", MaxBizSlots: ptr.To(int64(4))},
+		Resources: map[corev1.ResourceName]model.NodeResource{
+			corev1.ResourceMemory: {
+				Capacity:    resource.MustParse("8Gi"),
+				Allocatable: resource.MustParse("6Gi"),
+			},
+		},
+	})
+	assert.Len(t, notified, 1)
+	assert.Equal(t, "amd64", notified[0].Status.NodeInfo.Architecture)
+	assert.Equal(t, int64(4), notified[0].Status.Capacity.Pods().Value())
+	assert.True(t, notified[0].Status.Allocatable.Memory().Equal(resource.MustParse("6Gi")))
+	assert.Equal(t, int64(4), notified[0].Status.Allocatable.Name(model.ResourceNameBizSlots, resource.DecimalSI).Value())
+
+	// a base reporting no biz slot can host no biz
+	nodeProvider.Notify(model.NodeStatusData{SystemInfo: &model.SystemInfo{MaxBizSlots: ptr.To(int64(0))}})
+	assert.Len(t, notified, 2)
+	assert.Equal(t, int64(0), notified[1].Status.Capacity.Pods().Value())
+	assert.Equal(t, int64(0), notified[1].Status.Allocatable.Name(model.ResourceNameBizSlots, resource.DecimalSI).Value())
+
+	// a base not reporting biz slots is unlimited
+	nodeProvider.Notify(model.NodeStatusData{SystemInfo: &model.SystemInfo{}})
+	assert.Len(t, notified, 3)
+	assert.Equal(t, int64(model.NodeDefaultMaxPods), notified[2].Status.Capacity.Pods().Value())
+	assert.NotContains(t, notified[2].Status.Allocatable, model.ResourceNameBizSlots)
+
+	nodeInfo := utils.ConvertNodeToNodeInfo(node)
+	assert.Equal(t, ptr.To(int64(8)), nodeInfo.SystemInfo.MaxBizSlots)
+	assert.Equal(t, "arm64", nodeInfo.SystemInfo.Arch)
+	assert.Equal(t, "17.0.1", nodeInfo.SystemInfo.RuntimeVersion)
+	assert.Equal(t, "1.2.3", nodeInfo.SystemInfo.FrameworkVersion)
+}
+
+func TestNormalizeBizSlots(t *testing.T) {
+	node := &corev1.Node{
+		Status: corev1.NodeStatus{
+			Capacity: corev1.ResourceList{model.ResourceNameBizSlots: resource.MustParse("4")},
+			Allocatable: corev1.ResourceList{
+				model.ResourceNameBizSlots: resource.MustParse("10"),
+			},
+		},
+	}
+	normalizeBizSlots(node)
+	assert.Equal(t, int64(4), node.Status.Allocatable.Name(model.ResourceNameBizSlots, resource.DecimalSI).Value())
+
+	delete(node.Status.Capacity, model.ResourceNameBizSlots)
+	normalizeBizSlots(node)
+	assert.NotContains(t, node.Status.Allocatable, model.ResourceNameBizSlots)
+}
+
+// fakeCache reads objects from the fake client
+type fakeCache struct {
+	cache.Cache
+	Client client.Client
+}
+
+func (c fakeCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return c.Client.Get(ctx, key, obj, opts...)
+}
@@ -0,0 +1,374 @@
+/**
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/koupleless/virtual-kubelet/common/utils"
+	"github.com/koupleless/virtual-kubelet/model"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// resolveContainerEnv returns a copy of the container with its environment resolved the way kubelet does:
+// envFrom sources are expanded, valueFrom references are looked up, and $(VAR) references are replaced,
+// so the tunnel gets plain env values. A missing reference which is not optional returns an error, so does a biz key changed by the resolution,
+// because the biz is tracked by the key of the container in spec.
+func (b *VPodProvider) resolveContainerEnv(ctx context.Context, pod *corev1.Pod, container *corev1.Container) (*corev1.Container, error) {
+	resolved := container.DeepCopy()
+	if len(container.EnvFrom) == 0 && !hasEnvReference(container.Env) {
+		return resolved, nil
+	}
+
+	env := make([]corev1.EnvVar, 0, len(container.Env))
+	nameToIndex := make(map[string]int)
+	mapping := make(map[string]string)
+	setEnv := func(name, value string) {
+		mapping[name] = value
+		if i, has := nameToIndex[name]; has {
+			env[i].Value = value
+			return
+		}
+		nameToIndex[name] = len(env)
+		env = append(env, corev1.EnvVar{Name: name, Value: value})
+	}
+
+	for _, envFrom := range container.EnvFrom {
+		var data map[string]string
+		switch {
+		case envFrom.ConfigMapRef != nil:
+			configMap := &corev1.ConfigMap{}
+			optional := envFrom.ConfigMapRef.Optional != nil && *envFrom.ConfigMapRef.Optional
+			if err := b.getObject(ctx, pod.Namespace, envFrom.ConfigMapRef.Name, configMap); err != nil {
+				if optional && apierrors.IsNotFound(err) {
+					continue
+				}
+				return nil, fmt.Errorf("couldn't get configMap %s/%s: %v", pod.Namespace, envFrom.ConfigMapRef.Name, err)
+			}
+			data = configMap.Data
+		case envFrom.SecretRef != nil:
+			secret := &corev1.Secret{}
+			optional := envFrom.SecretRef.Optional != nil && *envFrom.SecretRef.Optional
+			if err := b.getObject(ctx, pod.Namespace, envFrom.SecretRef.Name, secret); err != nil {
+				if optional && apierrors.IsNotFound(err) {
+					continue
+				}
+				return nil, fmt.Errorf("couldn't get secret %s/%s: %v", pod.Namespace, envFrom.SecretRef.Name, err)
+			}
+			data = make(map[string]string, len(secret.Data))
+			for key, value := range secret.Data {
+				data[key] = string(value)
+			}
+		}
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := envFrom.Prefix + key
+			if len(validation.IsEnvVarName(name)) > 0 {
+				// same as kubelet, invalid keys are skipped
+				log.G(ctx).Warnf("skip invalid env name %s of container %s", name, utils.GetContainerKey(utils.GetPodKey(pod), container.Name))
+				continue
+			}
+			setEnv(name, data[key])
+		}
+	}
+
+	for _, envVar := range container.Env {
+		value := envVar.Value
+		if envVar.ValueFrom == nil {
+			setEnv(envVar.Name, expandEnvRefs(value, mapping))
+			continue
+		}
+		var err error
+		var found bool
+		switch source := envVar.ValueFrom; {
+		case source.FieldRef != nil:
+			value, err = b.podFieldValue(pod, source.FieldRef.FieldPath)
+			found = err == nil
+		case source.ResourceFieldRef != nil:
+			value, err = b.containerResourceValue(ctx, pod, container, source.ResourceFieldRef)
+			found = err == nil
+		case source.ConfigMapKeyRef != nil:
+			value, found, err = b.configMapKeyValue(ctx, pod.Namespace, source.ConfigMapKeyRef)
+		case source.SecretKeyRef != nil:
+			value, found, err = b.secretKeyValue(ctx, pod.Namespace, source.SecretKeyRef)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			setEnv(envVar.Name, value)
+		}
+	}
+
+	resolved.Env = env
+	resolved.EnvFrom = nil
+	if b.bizContainerMatcher.IsBizContainer(pod, container) {
+		specKey := b.bizKeyStrategy.BizUniqueKey(pod, container)
+		if key := b.bizKeyStrategy.BizUniqueKey(pod, resolved); key != specKey {
+			return nil, fmt.Errorf("biz key of container %s is resolved as %q instead of %q, the env of the biz key can't refer to other values", container.Name, key, specKey)
+		}
+	}
+	return resolved, nil
+}
+
+// hasEnvReference checks whether any env var refers to other values
+func hasEnvReference(env []corev1.EnvVar) bool {
+	for _, envVar := range env {
+		if envVar.ValueFrom != nil || strings.Contains(envVar.Value, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// expandEnvRefs replaces the $(VAR) references to defined env vars in the input, $$ escapes $,
+// and references to undefined env vars are kept as is, same as kubelet
+func expandEnvRefs(input string, mapping map[string]string) string {
+	var builder strings.Builder
+	for i := 0; i < len(input); i++ {
+		if input[i] != '$' || i+1 >= len(input) {
+			builder.WriteByte(input[i])
+			continue
+		}
+		switch input[i+1] {
+		case '$':
+			builder.WriteByte('$')
+			i++
+		case '(':
+			end := strings.IndexByte(input[i+2:], ')')
+			if end < 0 {
+				builder.WriteByte(input[i])
+				continue
+			}
+			name := input[i+2 : i+2+end]
+			if value, has := mapping[name]; has {
+				builder.WriteString(value)
+			} else {
+				builder.WriteString(input[i : i+3+end])
+			}
+			i += 2 + end
+		default:
+			builder.WriteByte(input[i])
+		}
+	}
+	return builder.String()
+}
+
+// getObject gets the object in the namespace of the pod from the apiserver, ConfigMaps and Secrets are not watched by the cache
+func (b *VPodProvider) getObject(ctx context.Context, namespace, name string, obj client.Object) error {
+	if b.kubeReader == nil {
+		return fmt.Errorf("no client to get %s/%s", namespace, name)
+	}
+	return b.kubeReader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj)
+}
+
+// configMapKeyValue returns the value of the key of the ConfigMap, not found is returned if it is optional and missing
+func (b *VPodProvider) configMapKeyValue(ctx context.Context, namespace string, ref *corev1.ConfigMapKeySelector) (string, bool, error) {
+	optional := ref.Optional != nil && *ref.Optional
+	configMap := &corev1.ConfigMap{}
+	if err := b.getObject(ctx, namespace, ref.Name, configMap); err != nil {
+		if optional && apierrors.IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("couldn't get configMap %s/%s: %v", namespace, ref.Name, err)
+	}
+	value, has := configMap.Data[ref.Key]
+	if !has {
+		if optional {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("couldn't find key %s in ConfigMap %s/%s", ref.Key, namespace, ref.Name)
+	}
+	return value, true, nil
+}
+
+// secretKeyValue returns the value of the key of the Secret, not found is returned if it is optional and missing
+func (b *VPodProvider) secretKeyValue(ctx context.Context, namespace string, ref *corev1.SecretKeySelector) (string, bool, error) {
+	optional := ref.Optional != nil && *ref.Optional
+	secret := &corev1.Secret{}
+	if err := b.getObject(ctx, namespace, ref.Name, secret); err != nil {
+		if optional && apierrors.IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("couldn't get secret %s/%s: %v", namespace, ref.Name, err)
+	}
+	value, has := secret.Data[ref.Key]
+	if !has {
+		if optional {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("couldn't find key %s in Secret %s/%s", ref.Key, namespace, ref.Name)
+	}
+	return string(value), true, nil
+}
+
+// podFieldValue returns the value of the downward API field of the pod, the pod and host IP are the IP of the base
+func (b *VPodProvider) podFieldValue(pod *corev1.Pod, fieldPath string) (string, error) {
+	if key, has := subscriptOf(fieldPath, "metadata.labels"); has {
+		return pod.Labels[key], nil
+	}
+	if key, has := subscriptOf(fieldPath, "metadata.annotations"); has {
+		return pod.Annotations[key], nil
+	}
+	switch fieldPath {
+	case "metadata.name":
+		return pod.Name, nil
+	case "metadata.namespace":
+		return pod.Namespace, nil
+	case "metadata.uid":
+		return string(pod.UID), nil
+	case "spec.nodeName":
+		return utils.OrElse(pod.Spec.NodeName, b.nodeName), nil
+	case "spec.serviceAccountName":
+		return pod.Spec.ServiceAccountName, nil
+	case "status.hostIP", "status.hostIPs", "status.podIP", "status.podIPs":
+		return b.localIP.Load().(string), nil
+	}
+	return "", fmt.Errorf("unsupported fieldPath: %s", fieldPath)
+}
+
+// subscriptOf returns the key of the field path like prefix['key']
+func subscriptOf(fieldPath, prefix string) (string, bool) {
+	if !strings.HasPrefix(fieldPath, prefix+"['") || !strings.HasSuffix(fieldPath, "']") {
+		return "", false
+	}
+	return fieldPath[len(prefix)+2 : len(fieldPath)-2], true
+}
+
+// containerResourceValue returns the resource of the container in the unit of the divisor rounded up,
+// an unset limit is the allocatable of the vnode, same as kubelet
+func (b *VPodProvider) containerResourceValue(ctx context.Context, pod *corev1.Pod, container *corev1.Container, ref *corev1.ResourceFieldSelector) (string, error) {
+	target := container
+	if ref.ContainerName != "" && ref.ContainerName != container.Name {
+		target = nil
+		for i := range pod.Spec.Containers {
+			if pod.Spec.Containers[i].Name == ref.ContainerName {
+				target = &pod.Spec.Containers[i]
+			}
+		}
+		if target == nil {
+			return "", fmt.Errorf("container %s of resourceFieldRef not found", ref.ContainerName)
+		}
+	}
+
+	resources, resourceName, found := strings.Cut(ref.Resource, ".")
+	if !found || (resources != "limits" && resources != "requests") {
+		return "", fmt.Errorf("unsupported container resource: %s", ref.Resource)
+	}
+	name := corev1.ResourceName(resourceName)
+	if name != corev1.ResourceCPU && name != corev1.ResourceMemory && name != corev1.ResourceEphemeralStorage {
+		return "", fmt.Errorf("unsupported container resource: %s", ref.Resource)
+	}
+	var quantity resource.Quantity
+	var has bool
+	if resources == "limits" {
+		quantity, has = target.Resources.Limits[name]
+		if !has {
+			node := &corev1.Node{}
+			if err := b.getObject(ctx, "", b.nodeName, node); err != nil {
+				return "", fmt.Errorf("couldn't get node %s: %v", b.nodeName, err)
+			}
+			quantity = node.Status.Allocatable[name]
+		}
+	} else {
+		quantity = target.Resources.Requests[name]
+	}
+
+	divisor := ref.Divisor
+	if divisor.IsZero() {
+		divisor = resource.MustParse("1")
+	}
+	if name == corev1.ResourceCPU {
+		return fmt.Sprint(int64(math.Ceil(float64(quantity.MilliValue()) / float64(divisor.MilliValue())))), nil
+	}
+	return fmt.Sprint(int64(math.Ceil(float64(quantity.Value()) / float64(divisor.Value())))), nil
+}
+
+// handleContainerConfigError keeps the container waiting in CreateContainerConfigError when its env can't be resolved,
+// and retries the start by retry with the restart backoff until the references are found, same as kubelet
+func (b *VPodProvider) handleContainerConfigError(ctx context.Context, pod *corev1.Pod, container corev1.Container, err error, retry func(pod *corev1.Pod, container corev1.Container)) {
+	podKey := utils.GetPodKey(pod)
+	containerKey := utils.GetContainerKey(podKey, container.Name)
+	bizKey := b.bizKeyStrategy.BizUniqueKey(pod, &container)
+	changeTime := time.Now()
+	b.vPodStore.ResetBizStatus(podKey, model.BizStatusData{
+		Key:        bizKey,
+		Name:       container.Name,
+		PodKey:     podKey,
+		State:      string(model.BizStateResolved),
+		ChangeTime: changeTime,
+		Reason:     model.ContainerReasonCreateContainerConfigError,
+		Message:    err.Error(),
+	})
+
+	b.bizLock.Lock()
+	b.restartBackoff.Next(containerKey, changeTime)
+	delay := b.restartBackoff.Get(containerKey)
+	b.bizLock.Unlock()
+	log.G(ctx).WithError(err).Warnf("failed to resolve env of container %s, retry after %s", containerKey, delay)
+	time.AfterFunc(delay, func() {
+		b.retryContainerConfig(ctx, podKey, container.Name, bizKey, changeTime, retry)
+	})
+	b.syncPodStatusToKube(ctx, podKey)
+}
+
+// retryContainerConfig retries the start of the container if it is still waiting in the same config error
+func (b *VPodProvider) retryContainerConfig(ctx context.Context, podKey, containerName, bizKey string, changeTime time.Time, retry func(pod *corev1.Pod, container corev1.Container)) {
+	pod := b.vPodStore.GetPodByKey(podKey)
+	if pod == nil || pod.DeletionTimestamp != nil {
+		return
+	}
+	latest, has := b.vPodStore.GetBizStatuses(podKey)[containerName]
+	if !has || latest.Key != bizKey || !latest.ChangeTime.Equal(changeTime) {
+		// the container changed during backoff
+		return
+	}
+	var container *corev1.Container
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == containerName {
+			container = &pod.Spec.Containers[i]
+		}
+	}
+	if container == nil || b.bizKeyStrategy.BizUniqueKey(pod, container) != bizKey {
+		return
+	}
+
+	log.G(ctx).Infof("retry to start container %s with config error", utils.GetContainerKey(podKey, containerName))
+	b.vPodStore.ResetBizStatus(podKey, model.BizStatusData{
+		Key:        bizKey,
+		Name:       containerName,
+		PodKey:     podKey,
+		State:      string(model.BizStateResolved),
+		ChangeTime: time.Now(),
+		Reason:     model.ContainerReasonContainerCreating,
+	})
+	retry(pod, *container)
+	b.syncPodStatusToKube(ctx, podKey)
+}
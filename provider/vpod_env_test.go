@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/koupleless/virtual-kubelet/model"
+	"github.com/koupleless/virtual-kubelet/tunnel"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// recordingTunnel records the containers started by the provider
+type recordingTunnel struct {
+	*tunnel.MockTunnel
+	lock    sync.Mutex
+	started []corev1.Container
+}
+
+func (r *recordingTunnel) StartBiz(nodeName, podKey string, container *corev1.Container) error {
+	r.lock.Lock()
+	r.started = append(r.started, *container)
+	r.lock.Unlock()
+	return r.MockTunnel.StartBiz(nodeName, podKey, container)
+}
+
+func TestResolveContainerEnv(t *testing.T) {
+	fakeClient := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "biz-config", Namespace: "default"},
+			Data:       map[string]string{"LOG_LEVEL": "info", "DB_HOST": "db", "IN=VALID": "x"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "biz-secret", Namespace: "default"},
+			Data:       map[string][]byte{"password": []byte("s3cret")},
+		},
+	).Build()
+	// the referenced objects are read from the apiserver instead of the cached client
+	provider, _ := newTestVPodProvider(t, nil, fake.NewClientBuilder().Build())
+	provider.SetKubeReader(fakeClient)
+	provider.SetLocalIP("10.0.0.1")
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default", UID: "uid-1", Labels: map[string]string{"app": "biz1"}},
+	}
+	container := &corev1.Container{
+		Name:  "biz1",
+		Image: "biz1.jar",
+		EnvFrom: []corev1.EnvFromSource{
+			{Prefix: "CFG_", ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "biz-config"}}},
+			{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "absent"}, Optional: ptr.To(true)}},
+		},
+		Env: []corev1.EnvVar{
+			{Name: "BIZ_VERSION", Value: "0.0.1"},
+			{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+			{Name: "APP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.labels['app']"}}},
+			{Name: "POD_IP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"}}},
+			{Name: "PASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "biz-secret"}, Key: "password",
+			}}},
+			{Name: "OPTIONAL", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "biz-config"}, Key: "absent", Optional: ptr.To(true),
+			}}},
+			{Name: "MEMORY_MB", ValueFrom: &corev1.EnvVarSource{ResourceFieldRef: &corev1.ResourceFieldSelector{
+				Resource: "limits.memory", Divisor: resource.MustParse("1Mi"),
+			}}},
+			{Name: "DSN", Value: "$(CFG_DB_HOST):$(UNDEFINED)/$$(POD_NAME)"},
+		},
+		Resources: corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+		},
+	}
+
+	resolved, err := provider.resolveContainerEnv(context.Background(), pod, container)
+	assert.NoError(t, err)
+	assert.Nil(t, resolved.EnvFrom)
+	assert.Equal(t, []corev1.EnvVar{
+		{Name: "CFG_DB_HOST", Value: "db"},
+		{Name: "CFG_LOG_LEVEL", Value: "info"},
+		{Name: "BIZ_VERSION", Value: "0.0.1"},
+		{Name: "POD_NAME", Value: "test-pod"},
+		{Name: "APP", Value: "biz1"},
+		{Name: "POD_IP", Value: "10.0.0.1"},
+		{Name: "PASSWORD", Value: "s3cret"},
+		{Name: "MEMORY_MB", Value: "512"},
+		{Name: "DSN", Value: "db:$(UNDEFINED)/$(POD_NAME)"},
+	}, resolved.Env)
+	// the container of the pod is kept as is
+	assert.Len(t, container.EnvFrom, 2)
+
+	container.Env = append(container.Env, corev1.EnvVar{Name: "REQUIRED", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "biz-config"}, Key: "absent",
+	}}})
+	_, err = provider.resolveContainerEnv(context.Background(), pod, container)
+	assert.EqualError(t, err, "couldn't find key absent in ConfigMap default/biz-config")
+
+	// the biz is tracked by the key of the spec, so the env of the key can't refer to other values
+	container.Env = []corev1.EnvVar{{Name: "BIZ_VERSION", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "biz-config"}, Key: "DB_HOST",
+	}}}}
+	_, err = provider.resolveContainerEnv(context.Background(), pod, container)
+	assert.ErrorContains(t, err, `biz key of container biz1 is resolved as "biz1:db" instead of "biz1:"`)
+}
+
+func TestCreatePod_ContainerConfigError(t *testing.T) {
+	fakeClient := fake.NewClientBuilder().Build()
+	tl := &recordingTunnel{MockTunnel: &tunnel.MockTunnel{}}
+	provider, notified := newTestVPodProvider(t, tl, fakeClient)
+	pod := buildUpdatedPod("0.0.1")
+	pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, corev1.EnvVar{Name: "LOG_LEVEL", ValueFrom: &corev1.EnvVarSource{
+		ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "biz-config"}, Key: "LOG_LEVEL"},
+	}})
+
+	ctx := context.Background()
+	assert.NoError(t, provider.CreatePod(ctx, pod))
+	<-notified
+	configError := <-notified
+	assert.Equal(t, corev1.PodPending, configError.Status.Phase)
+	waiting := configError.Status.ContainerStatuses[0].State.Waiting
+	assert.Equal(t, model.ContainerReasonCreateContainerConfigError, waiting.Reason)
+	assert.Contains(t, waiting.Message, "biz-config")
+	assert.Empty(t, tl.started)
+
+	// the start is retried once the ConfigMap is created
+	assert.NoError(t, fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "biz-config", Namespace: "default"},
+		Data:       map[string]string{"LOG_LEVEL": "debug"},
+	}))
+	bizStatus := provider.vPodStore.GetBizStatuses("default/test-pod")["biz1"]
+	provider.retryContainerConfig(ctx, "default/test-pod", "biz1", bizStatus.Key, bizStatus.ChangeTime, func(pod *corev1.Pod, container corev1.Container) {
+		provider.handleBizBatchStart(ctx, pod, []corev1.Container{container})
+	})
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+	assert.Len(t, tl.started, 1)
+	assert.Equal(t, []corev1.EnvVar{{Name: "BIZ_VERSION", Value: "0.0.1"}, {Name: "LOG_LEVEL", Value: "debug"}}, tl.started[0].Env)
+	assert.Equal(t, model.ContainerReasonContainerCreating, provider.vPodStore.GetBizStatuses("default/test-pod")["biz1"].Reason)
+}
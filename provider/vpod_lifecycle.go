@@ -0,0 +1,300 @@
+/**
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/koupleless/virtual-kubelet/common/utils"
+	"github.com/koupleless/virtual-kubelet/model"
+	"github.com/koupleless/virtual-kubelet/tunnel"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultLifecycleHandlerTimeout is the timeout of HTTP lifecycle hooks without deadline
+const defaultLifecycleHandlerTimeout = 30 * time.Second
+
+// runLifecycleHandler executes the lifecycle hook of the biz container before the deadline of ctx,
+// HTTP hooks go to the base and exec hooks go through the tunnel if it implements tunnel.BizCommandExecutor
+func (b *VPodProvider) runLifecycleHandler(ctx context.Context, pod *corev1.Pod, container *corev1.Container, handler *corev1.LifecycleHandler) error {
+	switch {
+	case handler.HTTPGet != nil:
+		port, err := resolveProbePort(handler.HTTPGet.Port, container)
+		if err != nil {
+			return err
+		}
+		timeout := defaultLifecycleHandlerTimeout
+		if deadline, has := ctx.Deadline(); has {
+			timeout = time.Until(deadline)
+		}
+		if timeout <= 0 {
+			return ctx.Err()
+		}
+		success, message := httpProbe(handler.HTTPGet, utils.OrElse(handler.HTTPGet.Host, b.localIP.Load().(string)), port, timeout)
+		if !success {
+			return errors.New(message)
+		}
+		return nil
+	case handler.Exec != nil:
+		executor, ok := b.tunnel.(tunnel.BizCommandExecutor)
+		if !ok {
+			return fmt.Errorf("exec hook is not supported by tunnel %s", b.tunnel.Key())
+		}
+		output, err := executor.ExecBizCommand(ctx, b.nodeName, utils.GetPodKey(pod), container, handler.Exec.Command)
+		if err != nil {
+			return fmt.Errorf("%s %s", err.Error(), strings.TrimSpace(output))
+		}
+		return nil
+	case handler.Sleep != nil:
+		select {
+		case <-time.After(time.Duration(handler.Sleep.Seconds) * time.Second):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errors.New("unsupported lifecycle handler")
+}
+
+// terminatePod shuts down the biz of the deleted pod gracefully before the deadline: runs the preStop hooks, deactivates the biz,
+// uninstalls it and waits for it stopped, then removes the pod from provider with all containers terminated.
+// The biz is uninstalled and the pod is finalized anyway when the deadline passes.
+func (b *VPodProvider) terminatePod(ctx context.Context, pod *corev1.Pod, deadline time.Time) {
+	podKey := utils.GetPodKey(pod)
+	logger := log.G(ctx).WithField("podKey", podKey)
+	logger.Infof("TerminatePodStarted, deadline %s", deadline)
+
+	terminateCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	// the pod is not ready once terminating
+	b.prober.removePod(pod)
+	b.syncPodStatusToKube(ctx, podKey)
+
+	// preStop hooks run in parallel like kubelet
+	wg := sync.WaitGroup{}
+	for _, container := range pod.Spec.Containers {
+		if container.Lifecycle == nil || container.Lifecycle.PreStop == nil || !b.bizContainerMatcher.IsBizContainer(pod, &container) {
+			continue
+		}
+		wg.Add(1)
+		go func(container corev1.Container) {
+			defer wg.Done()
+			if err := b.runLifecycleHandler(terminateCtx, pod, &container, container.Lifecycle.PreStop); err != nil {
+				logger.WithError(err).Warnf("preStop hook of container %s failed", container.Name)
+			}
+		}(container)
+	}
+	wg.Wait()
+
+	if deactivator, ok := b.tunnel.(tunnel.BizDeactivator); ok && terminateCtx.Err() == nil {
+		for _, container := range pod.Spec.Containers {
+			if !b.bizContainerMatcher.IsBizContainer(pod, &container) {
+				continue
+			}
+			if err := deactivator.DeactivateBiz(b.nodeName, podKey, &container); err != nil {
+				logger.WithError(err).Warnf("failed to deactivate biz of container %s", container.Name)
+			}
+		}
+	}
+
+	uninstallTime := time.Now()
+	b.handleBizBatchStop(ctx, pod, b.runningContainersOf(pod))
+
+	graceful := terminateCtx.Err() == nil && utils.CheckAndFinallyCall(terminateCtx, func(context.Context) (bool, error) {
+		return b.isBizStopped(pod, uninstallTime), nil
+	}, time.Until(deadline), time.Second, func() {}, func() {
+		logger.Warn("biz is not stopped before the termination grace period, force terminate pod")
+	}) == nil
+	b.finalizePod(ctx, pod, graceful)
+}
+
+// isBizStopped checks whether all biz containers of the pod are reported stopped after uninstalled
+func (b *VPodProvider) isBizStopped(pod *corev1.Pod, uninstallTime time.Time) bool {
+	bizStatuses := b.vPodStore.GetBizStatuses(utils.GetPodKey(pod))
+	for _, container := range pod.Spec.Containers {
+		if !b.bizContainerMatcher.IsBizContainer(pod, &container) {
+			continue
+		}
+		bizStatus, has := bizStatuses[container.Name]
+		if !has {
+			return false
+		}
+		if !isBizUninstalled(bizStatus, uninstallTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// isBizUninstalled checks whether the biz status shows the biz is terminated after uninstalled
+func isBizUninstalled(bizStatus model.BizStatusData, uninstallTime time.Time) bool {
+	switch model.BizState(strings.ToUpper(bizStatus.State)) {
+	case model.BizStateStopped, model.BizStateBroken:
+		return true
+	case model.BizStateUnResolved:
+		// unresolved before uninstalling means not installed yet
+		return !bizStatus.ChangeTime.Before(uninstallTime)
+	}
+	return false
+}
+
+// finalizePod removes the terminating pod from provider and notifies the pod with all containers terminated,
+// containers not stopped gracefully are terminated with exit code 137 like killed by kubelet.
+// The pod recreated with the same name during the termination is kept.
+func (b *VPodProvider) finalizePod(ctx context.Context, terminatingPod *corev1.Pod, graceful bool) {
+	podKey := utils.GetPodKey(terminatingPod)
+	defer func() {
+		b.bizLock.Lock()
+		delete(b.terminatingPods, terminatingPod.UID)
+		b.bizLock.Unlock()
+	}()
+
+	pod := b.vPodStore.GetPodByKey(podKey)
+	if pod == nil || pod.UID != terminatingPod.UID {
+		return
+	}
+	podStatus, err := b.GetPodStatus(ctx, pod)
+	if err != nil {
+		podStatus = pod.Status.DeepCopy()
+	}
+
+	now := metav1.Now()
+	failed := false
+	for i := range podStatus.ContainerStatuses {
+		containerStatus := &podStatus.ContainerStatuses[i]
+		containerStatus.Ready = false
+		if containerStatus.State.Terminated == nil {
+			terminated := &corev1.ContainerStateTerminated{
+				ExitCode:   0,
+				Reason:     model.ContainerReasonBizCompleted,
+				FinishedAt: now,
+			}
+			if !graceful {
+				terminated.ExitCode = 137
+				terminated.Reason = model.ContainerReasonBizError
+				terminated.Message = "biz is not stopped before the termination grace period"
+			}
+			if containerStatus.State.Running != nil {
+				terminated.StartedAt = containerStatus.State.Running.StartedAt
+			}
+			containerStatus.State = corev1.ContainerState{Terminated: terminated}
+		}
+		if containerStatus.State.Terminated.ExitCode != 0 {
+			failed = true
+		}
+	}
+	podStatus.Phase = corev1.PodSucceeded
+	if failed {
+		podStatus.Phase = corev1.PodFailed
+	}
+	for i := range podStatus.Conditions {
+		podStatus.Conditions[i].Status = corev1.ConditionFalse
+	}
+
+	b.vPodStore.DeletePod(podKey)
+	b.forgetRestarts(pod)
+
+	podCopy := pod.DeepCopy()
+	podStatus.DeepCopyInto(&podCopy.Status)
+	log.G(ctx).WithField("podKey", podKey).Infof("TerminatePodFinished, graceful: %v", graceful)
+	b.notify(podCopy)
+}
+
+// postStartHook is the postStart hook run for an activation of the biz
+type postStartHook struct {
+	activatedAt time.Time // change time of the activated biz status
+	succeeded   bool
+}
+
+// handlePostStart runs the postStart hook once for each activation of the biz of the container
+func (b *VPodProvider) handlePostStart(ctx context.Context, pod *corev1.Pod, container *corev1.Container, bizStatus model.BizStatusData) {
+	if container.Lifecycle == nil || container.Lifecycle.PostStart == nil {
+		return
+	}
+
+	containerKey := utils.GetContainerKey(utils.GetPodKey(pod), container.Name)
+	b.bizLock.Lock()
+	hook := b.postStartHooks[containerKey]
+	started := hook != nil && hook.activatedAt.Equal(bizStatus.ChangeTime)
+	if !started {
+		hook = &postStartHook{activatedAt: bizStatus.ChangeTime}
+		b.postStartHooks[containerKey] = hook
+	}
+	b.bizLock.Unlock()
+
+	if !started {
+		go b.runPostStartHook(ctx, pod.DeepCopy(), *container, hook)
+	}
+}
+
+// applyPostStart keeps the running container not ready until the postStart hook of the latest activation succeeded
+func (b *VPodProvider) applyPostStart(pod *corev1.Pod, container *corev1.Container, containerStatus *corev1.ContainerStatus, bizStatus *model.BizStatusData) {
+	if container.Lifecycle == nil || container.Lifecycle.PostStart == nil || bizStatus == nil || containerStatus.State.Running == nil {
+		return
+	}
+
+	containerKey := utils.GetContainerKey(utils.GetPodKey(pod), container.Name)
+	b.bizLock.Lock()
+	hook := b.postStartHooks[containerKey]
+	succeeded := hook != nil && hook.activatedAt.Equal(bizStatus.ChangeTime) && hook.succeeded
+	b.bizLock.Unlock()
+
+	if !succeeded {
+		containerStatus.Ready = false
+	}
+}
+
+// runPostStartHook runs the postStart hook of the activated biz, the biz is taken as broken if the hook failed like kubelet killing the container
+func (b *VPodProvider) runPostStartHook(ctx context.Context, pod *corev1.Pod, container corev1.Container, hook *postStartHook) {
+	podKey := utils.GetPodKey(pod)
+	containerKey := utils.GetContainerKey(podKey, container.Name)
+	hookCtx, cancel := context.WithTimeout(ctx, defaultLifecycleHandlerTimeout)
+	defer cancel()
+	err := b.runLifecycleHandler(hookCtx, pod, &container, container.Lifecycle.PostStart)
+
+	b.bizLock.Lock()
+	latest := b.postStartHooks[containerKey] == hook
+	if latest && err == nil {
+		hook.succeeded = true
+	}
+	b.bizLock.Unlock()
+	if !latest {
+		// the biz is reinstalled or the pod is deleted during the hook
+		return
+	}
+	currentPod := b.vPodStore.GetPodByKey(podKey)
+	if currentPod == nil || currentPod.DeletionTimestamp != nil {
+		return
+	}
+	if err == nil {
+		b.syncPodStatusToKube(ctx, podKey)
+		return
+	}
+
+	message := fmt.Sprintf("PostStartHook failed: %s", err.Error())
+	log.G(ctx).Warnf("postStart hook of %s failed: %s", containerKey, err.Error())
+	if b.eventRecorder != nil {
+		b.eventRecorder.Event(currentPod, corev1.EventTypeWarning, model.ContainerReasonFailedPostStartHook, message)
+	}
+	b.breakBiz(ctx, currentPod, &container, model.ContainerReasonFailedPostStartHook, message, 137)
+}
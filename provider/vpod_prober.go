@@ -0,0 +1,390 @@
+/**
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/koupleless/virtual-kubelet/common/utils"
+	"github.com/koupleless/virtual-kubelet/tunnel"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+type probeType string
+
+const (
+	readinessProbe probeType = "Readiness"
+	livenessProbe  probeType = "Liveness"
+	startupProbe   probeType = "Startup"
+)
+
+// probeResult is the latest result of a probe for a running instance of a biz container
+type probeResult struct {
+	success   bool
+	startedAt time.Time // started time of the probed container instance, results of former instances are ignored
+}
+
+// bizProber runs the probes of biz containers, one worker per probe of a container like kubelet.
+// HTTP and TCP probes go to the base, exec probes go through the tunnel if it implements tunnel.BizCommandExecutor.
+type bizProber struct {
+	sync.Mutex
+
+	provider *VPodProvider
+	workers  map[string]*probeWorker // worker key to worker
+	results  map[string]probeResult  // worker key to the latest result
+}
+
+func newBizProber(provider *VPodProvider) *bizProber {
+	return &bizProber{
+		provider: provider,
+		workers:  make(map[string]*probeWorker),
+		results:  make(map[string]probeResult),
+	}
+}
+
+func probeWorkerKey(podKey, containerName string, probeType probeType) string {
+	return utils.GetContainerKey(podKey, containerName) + "/" + string(probeType)
+}
+
+// addPod starts the workers of the probes of biz containers in the pod which are not running yet
+func (p *bizProber) addPod(ctx context.Context, pod *corev1.Pod) {
+	p.Lock()
+	defer p.Unlock()
+
+	podKey := utils.GetPodKey(pod)
+	for _, container := range pod.Spec.Containers {
+		if !p.provider.bizContainerMatcher.IsBizContainer(pod, &container) {
+			continue
+		}
+		probes := map[probeType]*corev1.Probe{
+			readinessProbe: container.ReadinessProbe,
+			livenessProbe:  container.LivenessProbe,
+			startupProbe:   container.StartupProbe,
+		}
+		for probeType, probe := range probes {
+			key := probeWorkerKey(podKey, container.Name, probeType)
+			if probe == nil || p.workers[key] != nil {
+				continue
+			}
+			worker := &probeWorker{
+				prober:        p,
+				key:           key,
+				podKey:        podKey,
+				containerName: container.Name,
+				probeType:     probeType,
+				spec:          probe.DeepCopy(),
+				stopCh:        make(chan struct{}),
+			}
+			p.workers[key] = worker
+			go worker.run(ctx)
+		}
+	}
+}
+
+// removeContainers stops the workers of the containers and drops their results
+func (p *bizProber) removeContainers(pod *corev1.Pod, containers []corev1.Container) {
+	p.Lock()
+	defer p.Unlock()
+
+	podKey := utils.GetPodKey(pod)
+	for _, container := range containers {
+		for _, probeType := range []probeType{readinessProbe, livenessProbe, startupProbe} {
+			p.removeWorkerLocked(probeWorkerKey(podKey, container.Name, probeType))
+		}
+	}
+}
+
+// removePod stops the workers of all containers of the pod
+func (p *bizProber) removePod(pod *corev1.Pod) {
+	p.removeContainers(pod, pod.Spec.Containers)
+}
+
+func (p *bizProber) removeWorker(key string) {
+	p.Lock()
+	defer p.Unlock()
+	p.removeWorkerLocked(key)
+}
+
+func (p *bizProber) removeWorkerLocked(key string) {
+	if worker, has := p.workers[key]; has {
+		close(worker.stopCh)
+		delete(p.workers, key)
+	}
+	delete(p.results, key)
+}
+
+// setResult records the result of the probe, returns true if the result changed
+func (p *bizProber) setResult(key string, result probeResult) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if _, has := p.workers[key]; !has {
+		// the worker is removed
+		return false
+	}
+	previous, has := p.results[key]
+	p.results[key] = result
+	return !has || previous != result
+}
+
+// succeeded returns the result of the probe for the container instance started at startedAt, false if not probed yet
+func (p *bizProber) succeeded(podKey, containerName string, probeType probeType, startedAt time.Time) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	result, has := p.results[probeWorkerKey(podKey, containerName, probeType)]
+	return has && result.success && result.startedAt.Equal(startedAt)
+}
+
+// applyResults sets Started and Ready of the running container status by the startup and readiness probes of the container
+func (p *bizProber) applyResults(podKey string, container *corev1.Container, containerStatus *corev1.ContainerStatus) {
+	if containerStatus.State.Running == nil {
+		return
+	}
+	startedAt := containerStatus.State.Running.StartedAt.Time
+	if container.StartupProbe != nil {
+		started := p.succeeded(podKey, container.Name, startupProbe, startedAt)
+		containerStatus.Started = &started
+		if !started {
+			containerStatus.Ready = false
+			return
+		}
+	}
+	if container.ReadinessProbe != nil {
+		containerStatus.Ready = containerStatus.Ready && p.succeeded(podKey, container.Name, readinessProbe, startedAt)
+	}
+}
+
+// probeWorker runs one probe of a biz container periodically, the result is reset for each new running instance of the container
+type probeWorker struct {
+	prober        *bizProber
+	key           string
+	podKey        string
+	containerName string
+	probeType     probeType
+	spec          *corev1.Probe
+	stopCh        chan struct{}
+
+	startedAt  time.Time // started time of the probed container instance
+	lastResult bool
+	resultRun  int
+	onHold     bool // liveness or startup probe failed, wait for the next instance
+}
+
+func (w *probeWorker) run(ctx context.Context) {
+	period := time.Duration(utils.OrElse(w.spec.PeriodSeconds, 10)) * time.Second
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		if !w.doProbe(ctx) {
+			w.prober.removeWorker(w.key)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			w.prober.removeWorker(w.key)
+			return
+		case <-w.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// doProbe probes the container once, returns false if the container doesn't exist anymore
+func (w *probeWorker) doProbe(ctx context.Context) bool {
+	provider := w.prober.provider
+	pod := provider.vPodStore.GetPodByKey(w.podKey)
+	if pod == nil {
+		return false
+	}
+	var container *corev1.Container
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == w.containerName {
+			container = &pod.Spec.Containers[i]
+		}
+	}
+	if container == nil {
+		return false
+	}
+	var containerStatus *corev1.ContainerStatus
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == w.containerName {
+			containerStatus = &pod.Status.ContainerStatuses[i]
+		}
+	}
+	if containerStatus == nil || containerStatus.State.Running == nil {
+		// only running containers are probed
+		return true
+	}
+
+	startedAt := containerStatus.State.Running.StartedAt.Time
+	if !startedAt.Equal(w.startedAt) {
+		// a new instance of the container, liveness is assumed success before probed same as kubelet
+		w.startedAt = startedAt
+		w.resultRun = 0
+		w.onHold = false
+		w.prober.setResult(w.key, probeResult{success: w.probeType == livenessProbe, startedAt: startedAt})
+	}
+	if w.onHold {
+		return true
+	}
+	if time.Since(startedAt) < time.Duration(w.spec.InitialDelaySeconds)*time.Second {
+		return true
+	}
+	if w.probeType != startupProbe && container.StartupProbe != nil &&
+		!w.prober.succeeded(w.podKey, w.containerName, startupProbe, startedAt) {
+		// readiness and liveness probes wait for the startup probe
+		return true
+	}
+
+	success, message := w.prober.runProbe(ctx, pod, container, w.spec)
+	if w.lastResult == success {
+		w.resultRun++
+	} else {
+		w.lastResult = success
+		w.resultRun = 1
+	}
+	if (!success && w.resultRun < int(utils.OrElse(w.spec.FailureThreshold, 3))) ||
+		(success && w.resultRun < int(utils.OrElse(w.spec.SuccessThreshold, 1))) {
+		// keep the previous result until the threshold is reached
+		return true
+	}
+
+	changed := w.prober.setResult(w.key, probeResult{success: success, startedAt: startedAt})
+	if !success && w.probeType != readinessProbe {
+		w.onHold = true
+		provider.handleProbeFailure(ctx, pod, container, string(w.probeType), message)
+		return true
+	}
+	if changed {
+		log.G(ctx).Infof("%s probe of %s changed to %v: %s", w.probeType, utils.GetContainerKey(w.podKey, w.containerName), success, message)
+		provider.syncPodStatusToKube(ctx, w.podKey)
+	}
+	return true
+}
+
+// runProbe executes the probe handler against the biz, returns the result and the message of failure
+func (p *bizProber) runProbe(ctx context.Context, pod *corev1.Pod, container *corev1.Container, probe *corev1.Probe) (bool, string) {
+	timeout := time.Duration(utils.OrElse(probe.TimeoutSeconds, 1)) * time.Second
+	localIP := p.provider.localIP.Load().(string)
+	switch {
+	case probe.HTTPGet != nil:
+		port, err := resolveProbePort(probe.HTTPGet.Port, container)
+		if err != nil {
+			return false, err.Error()
+		}
+		return httpProbe(probe.HTTPGet, utils.OrElse(probe.HTTPGet.Host, localIP), port, timeout)
+	case probe.TCPSocket != nil:
+		port, err := resolveProbePort(probe.TCPSocket.Port, container)
+		if err != nil {
+			return false, err.Error()
+		}
+		return tcpProbe(utils.OrElse(probe.TCPSocket.Host, localIP), port, timeout)
+	case probe.Exec != nil:
+		executor, ok := p.provider.tunnel.(tunnel.BizCommandExecutor)
+		if !ok {
+			return false, "exec probe is not supported by tunnel " + p.provider.tunnel.Key()
+		}
+		execCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		output, err := executor.ExecBizCommand(execCtx, p.provider.nodeName, utils.GetPodKey(pod), container, probe.Exec.Command)
+		if err != nil {
+			return false, strings.TrimSpace(fmt.Sprintf("%s %s", err.Error(), output))
+		}
+		return true, output
+	case probe.GRPC != nil:
+		return false, "grpc probe is not supported by vpod"
+	}
+	return false, "missing probe handler"
+}
+
+// resolveProbePort resolves the port of probe handler, named ports are looked up in the container ports
+func resolveProbePort(port intstr.IntOrString, container *corev1.Container) (int, error) {
+	if port.Type == intstr.Int {
+		return port.IntValue(), nil
+	}
+	for _, containerPort := range container.Ports {
+		if containerPort.Name == port.StrVal {
+			return int(containerPort.ContainerPort), nil
+		}
+	}
+	if value, err := strconv.Atoi(port.StrVal); err == nil {
+		return value, nil
+	}
+	return 0, fmt.Errorf("port %s not found in container %s", port.StrVal, container.Name)
+}
+
+func httpProbe(handler *corev1.HTTPGetAction, host string, port int, timeout time.Duration) (bool, string) {
+	scheme := strings.ToLower(string(utils.OrElse(handler.Scheme, corev1.URISchemeHTTP)))
+	probeURL := &url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(host, strconv.Itoa(port)),
+	}
+	pathURL, err := url.Parse(handler.Path)
+	if err != nil {
+		return false, err.Error()
+	}
+	probeURL.Path = pathURL.Path
+	probeURL.RawQuery = pathURL.RawQuery
+
+	req, err := http.NewRequest(http.MethodGet, probeURL.String(), nil)
+	if err != nil {
+		return false, err.Error()
+	}
+	for _, header := range handler.HTTPHeaders {
+		if strings.EqualFold(header.Name, "Host") {
+			req.Host = header.Value
+			continue
+		}
+		req.Header.Add(header.Name, header.Value)
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		// same as kubelet, the certificate of the biz is not verified
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, DisableKeepAlives: true},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err.Error()
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusBadRequest {
+		return true, ""
+	}
+	return false, fmt.Sprintf("HTTP probe failed with statuscode: %d", resp.StatusCode)
+}
+
+func tcpProbe(host string, port int, timeout time.Duration) (bool, string) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), timeout)
+	if err != nil {
+		return false, err.Error()
+	}
+	_ = conn.Close()
+	return true, ""
+}
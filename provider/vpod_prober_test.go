@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/koupleless/virtual-kubelet/common/utils"
+	"github.com/koupleless/virtual-kubelet/model"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func buildProbedPod(probe *corev1.Probe, liveness bool) *corev1.Pod {
+	container := corev1.Container{
+		Name:  "biz1",
+		Image: "biz1.jar",
+		Env:   []corev1.EnvVar{{Name: "BIZ_VERSION", Value: "0.0.1"}},
+		Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+	}
+	if liveness {
+		container.LivenessProbe = probe
+	} else {
+		container.ReadinessProbe = probe
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyAlways,
+			Containers:    []corev1.Container{container},
+		},
+	}
+}
+
+func startProbedPod(provider *VPodProvider, pod *corev1.Pod) {
+	provider.vPodStore.PutPod(pod.DeepCopy())
+	provider.recordBizStatus(context.TODO(), "default/test-pod", model.BizStatusData{
+		Key:        utils.DefaultBizKeyStrategy.BizUniqueKey(pod, &pod.Spec.Containers[0]),
+		Name:       "biz1",
+		PodKey:     "default/test-pod",
+		State:      string(model.BizStateActivated),
+		ChangeTime: time.Now(),
+	})
+	provider.syncPodStatusToKube(context.TODO(), "default/test-pod")
+}
+
+func TestBizProber_Readiness(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/biz1/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverURL.Port())
+
+	provider, notified := newTestVPodProvider(t, nil, nil)
+	pod := buildProbedPod(&corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{Path: "/biz1/health", Port: intstr.FromInt32(int32(port))},
+		},
+		PeriodSeconds: 1,
+	}, false)
+	startProbedPod(provider, pod)
+
+	running := <-notified
+	assert.Equal(t, corev1.PodRunning, running.Status.Phase)
+	assert.False(t, running.Status.ContainerStatuses[0].Ready)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	provider.prober.addPod(ctx, pod)
+
+	ready := <-notified
+	assert.True(t, ready.Status.ContainerStatuses[0].Ready)
+	assert.Equal(t, corev1.ConditionTrue, ready.Status.Conditions[0].Status)
+
+	provider.prober.removePod(pod)
+	assert.Len(t, provider.prober.workers, 0)
+}
+
+func TestBizProber_LivenessFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	// nothing is listening on the port any more
+	_ = listener.Close()
+
+	provider, notified := newTestVPodProvider(t, nil, nil)
+	pod := buildProbedPod(&corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(int32(port))},
+		},
+		PeriodSeconds:    1,
+		FailureThreshold: 1,
+	}, true)
+	pod.Spec.RestartPolicy = corev1.RestartPolicyNever
+	startProbedPod(provider, pod)
+	<-notified
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	provider.prober.addPod(ctx, pod)
+
+	killed := <-notified
+	assert.Equal(t, corev1.PodFailed, killed.Status.Phase)
+	containerStatus := killed.Status.ContainerStatuses[0]
+	assert.Equal(t, int32(137), containerStatus.State.Terminated.ExitCode)
+	assert.Contains(t, containerStatus.State.Terminated.Message, "Liveness probe failed")
+	provider.prober.removePod(pod)
+}
+
+func TestResolveProbePort(t *testing.T) {
+	container := &corev1.Container{Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}}
+
+	port, err := resolveProbePort(intstr.FromInt32(1238), container)
+	assert.NoError(t, err)
+	assert.Equal(t, 1238, port)
+
+	port, err = resolveProbePort(intstr.FromString("http"), container)
+	assert.NoError(t, err)
+	assert.Equal(t, 8080, port)
+
+	_, err = resolveProbePort(intstr.FromString("grpc"), container)
+	assert.Error(t, err)
+}
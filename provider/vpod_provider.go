@@ -16,18 +16,23 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/koupleless/virtual-kubelet/tunnel"
 	"github.com/koupleless/virtual-kubelet/virtual_kubelet/node"
 	"github.com/koupleless/virtual-kubelet/virtual_kubelet/node/nodeutil"
-	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 
-	"github.com/google/go-cmp/cmp"
 	"github.com/koupleless/virtual-kubelet/common/tracker"
 	"github.com/koupleless/virtual-kubelet/common/utils"
 	"github.com/koupleless/virtual-kubelet/model"
@@ -47,11 +52,31 @@ var _ node.PodNotifier = &VPodProvider{}
 type VPodProvider struct {
 	Namespace string
 	nodeName  string
-	localIP   string
+	localIP   atomic.Value // ip of the base, used as the pod ip
 	client    client.Client
 	cache     cache.Cache
 	vPodStore *VPodStore // store the pod from provider
 
+	kubeReader client.Reader // reads the objects referred by the env of vpods from the apiserver, they are not watched by the cache
+
+	bizContainerMatcher model.BizContainerMatcher // decides which containers are biz modules
+	bizKeyStrategy      model.BizKeyStrategy      // unique key of biz modules
+	statusMapper        model.StatusMapper        // maps biz status to container status
+
+	restartBackoff  *flowcontrol.Backoff      // backoff of reinstalling broken biz, keyed by container key
+	bizLock         sync.Mutex                // guards restartedBiz, terminatedBiz, startAttempts, terminatingPods, postStartHooks, suspendedBiz and the transitions of pod updates
+	restartedBiz    map[string]time.Time      // container key to the change time of the broken biz status already handled
+	terminatedBiz   map[string]bool           // container keys of the biz terminated since the last activation, a restart is counted once activated again
+	startAttempts   map[string]int            // container key to the count of start attempts, a start timeout only applies to the latest attempt
+	bizStartTimeout time.Duration             // default deadline of a started biz to be activated, non-positive means no timeout
+	terminatingPods map[types.UID]bool        // uids of the pods being terminated gracefully
+	postStartHooks  map[string]*postStartHook // container key to the postStart hook of the latest activation
+	suspendedBiz    map[string]time.Time      // container key to the change time of the activated biz status deactivated for suspension
+
+	eventRecorder record.EventRecorder // records the events of pods, events are dropped if nil
+
+	prober *bizProber // runs the probes of biz containers
+
 	tunnel tunnel.Tunnel
 
 	port int
@@ -68,26 +93,85 @@ func (b *VPodProvider) NotifyPods(_ context.Context, cb func(*corev1.Pod)) {
 func NewVPodProvider(namespace, localIP, nodeName string, client client.Client, cache cache.Cache, tunnel tunnel.Tunnel) *VPodProvider {
 	provider := &VPodProvider{
 		Namespace: namespace,
-		localIP:   localIP,
 		nodeName:  nodeName,
 		client:    client,
 		cache:     cache,
 		tunnel:    tunnel,
 		vPodStore: NewVPodStore(),
+
+		bizContainerMatcher: utils.DefaultBizContainerMatcher,
+		bizKeyStrategy:      utils.DefaultBizKeyStrategy,
+		statusMapper:        utils.NewDefaultStatusMapper(),
+
+		restartBackoff: flowcontrol.NewBackOff(model.BizRestartBackOffInitialSeconds*time.Second, model.BizRestartBackOffMaxSeconds*time.Second),
+		restartedBiz:   make(map[string]time.Time),
+		terminatedBiz:  make(map[string]bool),
+
+		startAttempts:   make(map[string]int),
+		terminatingPods: make(map[types.UID]bool),
+		postStartHooks:  make(map[string]*postStartHook),
+		suspendedBiz:    make(map[string]time.Time),
 	}
+	provider.localIP.Store(localIP)
+	provider.kubeReader = client
+	provider.prober = newBizProber(provider)
 
 	return provider
 }
 
-// syncBizStatusToKube is a method of VPodProvider that synchronizes the status of related pods
-func (b *VPodProvider) syncBizStatusToKube(ctx context.Context, bizStatusData model.BizStatusData) {
+// SetKubeReader replaces the client reading the objects referred by the env of vpods, which should not be cached, must be called before the provider runs
+func (b *VPodProvider) SetKubeReader(reader client.Reader) {
+	b.kubeReader = reader
+}
+
+// SetBizContainerMatcher replaces the default matcher of biz containers, must be called before the provider runs
+func (b *VPodProvider) SetBizContainerMatcher(matcher model.BizContainerMatcher) {
+	b.bizContainerMatcher = matcher
+	b.vPodStore.bizContainerMatcher = matcher
+}
+
+// SetStatusMapper replaces the default mapper of biz status to container status, must be called before the provider runs
+func (b *VPodProvider) SetStatusMapper(mapper model.StatusMapper) {
+	b.statusMapper = mapper
+}
+
+// SetBizKeyStrategy replaces the default strategy of biz unique keys, must be called before the provider runs
+func (b *VPodProvider) SetBizKeyStrategy(strategy model.BizKeyStrategy) {
+	b.bizKeyStrategy = strategy
+}
+
+// SetBizStartTimeout sets the default deadline of a started biz to be activated, non-positive means no timeout, must be called before the provider runs
+func (b *VPodProvider) SetBizStartTimeout(timeout time.Duration) {
+	b.bizStartTimeout = timeout
+}
+
+// SetEventRecorder sets the recorder of pod events, must be called before the provider runs
+func (b *VPodProvider) SetEventRecorder(recorder record.EventRecorder) {
+	b.eventRecorder = recorder
+}
+
+// SetLocalIP updates the ip of the base, which is reported as the ip of the vpods on the next status sync.
+func (b *VPodProvider) SetLocalIP(localIP string) {
+	b.localIP.Store(localIP)
+}
+
+// syncPodStatusToKube is a method of VPodProvider that computes the status of the pod from the latest biz status snapshot and pushes it once
+func (b *VPodProvider) syncPodStatusToKube(ctx context.Context, podKey string) {
 	logger := log.G(ctx)
-	pod := b.vPodStore.GetPodByKey(bizStatusData.PodKey)
+	pod := b.vPodStore.GetPodByKey(podKey)
 	if pod == nil {
-		logger.Errorf("skip updating non-exist pod status for biz %s pod %s", bizStatusData.Key, bizStatusData.PodKey)
+		logger.Errorf("skip updating non-exist pod status for pod %s", podKey)
+		return
+	}
+	if isRejectedPod(pod) {
+		logger.Warnf("skip updating rejected pod status for pod %s", podKey)
+		return
+	}
+	podStatus, err := b.GetPodStatus(ctx, pod)
+	if err != nil {
+		logger.WithError(err).Errorf("failed to get pod status for pod %s", podKey)
 		return
 	}
-	podStatus, _ := b.GetPodStatus(ctx, pod, bizStatusData)
 
 	podCopy := pod.DeepCopy()
 	podStatus.DeepCopyInto(&podCopy.Status)
@@ -95,7 +179,8 @@ func (b *VPodProvider) syncBizStatusToKube(ctx context.Context, bizStatusData mo
 	b.notify(podCopy)
 }
 
-// SyncAllBizStatusToKube is a method of VPodProvider that synchronizes the information of all containers
+// SyncAllBizStatusToKube is a method of VPodProvider that synchronizes the information of all containers,
+// the status of each changed pod is pushed once for the whole batch
 func (b *VPodProvider) SyncAllBizStatusToKube(ctx context.Context, bizStatusDatas []model.BizStatusData) {
 	bizKeyToBizStatusData := make(map[string]model.BizStatusData)
 	for _, bizStatusData := range bizStatusDatas {
@@ -108,21 +193,34 @@ func (b *VPodProvider) SyncAllBizStatusToKube(ctx context.Context, bizStatusData
 		return pods[i].CreationTimestamp.UnixMilli() > pods[j].CreationTimestamp.UnixMilli()
 	})
 
-	// Initialize an empty slice to store updated container information
-	toUpdateBizStatusDatas := make([]model.BizStatusData, 0)
+	// the pods with at least one container status changed
+	toUpdatePodKeys := make([]string, 0)
 	// Get the current time to use for change time
 	now := time.Now()
 	// Iterate through each pod
 	for _, pod := range pods {
+		if isRejectedPod(pod) {
+			continue
+		}
 		// Get the key of the pod
 		podKey := utils.GetPodKey(pod)
+
+		namespace, name := utils.GetNameSpaceAndNameFromPodKey(podKey)
+		podFromKube := &corev1.Pod{}
+		err := b.cache.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, podFromKube)
+		if err != nil {
+			log.G(ctx).WithError(err).Errorf("Failed to get pod %s from cache", podKey)
+			continue
+		}
+
+		needSync := false
 		// Iterate through each container in the pod
 		for _, container := range pod.Spec.Containers {
 			// Get the unique key of the container
-			bizKey := utils.GetBizUniqueKey(&container)
+			bizKey := b.bizKeyStrategy.BizUniqueKey(pod, &container)
 			// Check if container information exists for the container key
 			bizStatusData, has := bizKeyToBizStatusData[bizKey]
-			// If container information does not exist, create a new deactivated instance
+			// If container information does not exist, create a new unresolved instance
 			if !has {
 				bizStatusData = model.BizStatusData{
 					Key:        bizKey,
@@ -133,28 +231,21 @@ func (b *VPodProvider) SyncAllBizStatusToKube(ctx context.Context, bizStatusData
 				}
 			}
 
-			namespace, name := utils.GetNameSpaceAndNameFromPodKey(bizStatusData.PodKey)
-			pod := &corev1.Pod{}
-			err := b.cache.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pod)
-			if err != nil {
-				log.G(ctx).WithError(err).Errorf("Failed to get pod %s from cache", podKey)
-				continue
-			}
-
 			// Attempt to update the container status
-			toUpdate := b.vPodStore.CheckContainerStatusNeedSync(pod, bizStatusData)
-			// If the update was successful, add the container information to the updated list
-			if toUpdate {
-				toUpdateBizStatusDatas = append(toUpdateBizStatusDatas, bizStatusData)
+			toUpdate := b.vPodStore.CheckContainerStatusNeedSync(podFromKube, bizStatusData)
+			if toUpdate && b.recordBizStatus(ctx, podKey, bizStatusData) {
+				needSync = true
 			}
 
 			log.G(ctx).Infof("container %s/%s need update: %v", podKey, bizKey, toUpdate)
 		}
+		if needSync {
+			toUpdatePodKeys = append(toUpdatePodKeys, podKey)
+		}
 	}
 
-	// Iterate through the provided container information and sync the related pod status
-	for _, toUpdateBizStatus := range toUpdateBizStatusDatas {
-		b.syncBizStatusToKube(ctx, toUpdateBizStatus)
+	for _, podKey := range toUpdatePodKeys {
+		b.syncPodStatusToKube(ctx, podKey)
 	}
 }
 
@@ -170,12 +261,99 @@ func (b *VPodProvider) SyncBizStatusToKube(ctx context.Context, bizStatusData mo
 
 	needSync := b.vPodStore.CheckContainerStatusNeedSync(pod, bizStatusData)
 	log.G(ctx).Infof("container %s/%s need update: %v", bizStatusData.PodKey, bizStatusData.Key, needSync)
-	if needSync {
+	if needSync && b.recordBizStatus(ctx, bizStatusData.PodKey, bizStatusData) {
 		// only when container status updated, update related pod status
-		b.syncBizStatusToKube(ctx, bizStatusData)
+		b.syncPodStatusToKube(ctx, bizStatusData.PodKey)
 	}
 }
 
+// recordBizStatus records the biz status reported for a container of the pod in store, and handles the change of the biz status if recorded.
+// It returns false if the biz status is ignored by the store.
+func (b *VPodProvider) recordBizStatus(ctx context.Context, podKey string, bizStatusData model.BizStatusData) bool {
+	if !b.vPodStore.PutBizStatus(podKey, bizStatusData) {
+		return false
+	}
+	b.handleBizStatusChange(ctx, podKey, bizStatusData)
+	return true
+}
+
+// handleBizStatusChange takes the actions driven by the latest biz status of a container, so GetPodStatus only computes the status:
+// the broken biz is reinstalled by the restart policy of the pod, the postStart hook runs once the biz is activated,
+// and the biz activated in suspended pod is deactivated
+func (b *VPodProvider) handleBizStatusChange(ctx context.Context, podKey string, bizStatusData model.BizStatusData) {
+	pod := b.vPodStore.GetPodByKey(podKey)
+	if pod == nil || pod.DeletionTimestamp != nil || isRejectedPod(pod) {
+		// terminating pod is never restarted
+		return
+	}
+	container := containerOf(pod, bizStatusData.Name)
+	if container == nil || !b.bizContainerMatcher.IsBizContainer(pod, container) || b.bizKeyStrategy.BizUniqueKey(pod, container) != bizStatusData.Key {
+		// the biz status of an old version of the container
+		return
+	}
+	if _, isServing := b.servingBizOf(podKey)[container.Name]; isServing {
+		return
+	}
+	containerStatus, err := b.statusMapper.MapBizStatus(container, nil, &bizStatusData)
+	if err != nil || containerStatus == nil {
+		log.G(ctx).Errorf("can't convert biz status to container status for container %s", utils.GetContainerKey(podKey, container.Name))
+		return
+	}
+
+	if containerStatus.State.Waiting != nil && containerStatus.State.Waiting.Reason == model.ContainerReasonBizBroken {
+		b.markTerminated(podKey, container.Name)
+		b.handleBrokenBiz(ctx, pod, container, bizStatusData)
+	}
+	if containerStatus.State.Running != nil {
+		b.countRestart(podKey, container.Name)
+		b.handlePostStart(ctx, pod, container, bizStatusData)
+	}
+	if isSuspendedPod(pod) {
+		b.suspendBiz(ctx, pod, container, bizStatusData)
+	}
+}
+
+// markTerminated records the biz of the container as terminated, so a restart is counted when it is activated again
+func (b *VPodProvider) markTerminated(podKey, containerName string) {
+	b.bizLock.Lock()
+	defer b.bizLock.Unlock()
+	b.terminatedBiz[utils.GetContainerKey(podKey, containerName)] = true
+}
+
+// countRestart increases the restart count of the container if its biz is activated after terminated, this is the only place counting restarts
+func (b *VPodProvider) countRestart(podKey, containerName string) {
+	containerKey := utils.GetContainerKey(podKey, containerName)
+	b.bizLock.Lock()
+	terminated := b.terminatedBiz[containerKey]
+	delete(b.terminatedBiz, containerKey)
+	b.bizLock.Unlock()
+	if !terminated {
+		return
+	}
+
+	pod := b.vPodStore.GetPodByKey(podKey)
+	if pod == nil {
+		return
+	}
+	podCopy := pod.DeepCopy()
+	for i := range podCopy.Status.ContainerStatuses {
+		if podCopy.Status.ContainerStatuses[i].Name == containerName {
+			podCopy.Status.ContainerStatuses[i].RestartCount++
+		}
+	}
+	b.vPodStore.PutPod(podCopy)
+}
+
+// containerOf returns the container of the pod with the name, nil if not found
+func containerOf(pod *corev1.Pod, name string) *corev1.Container {
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == name {
+			return &pod.Spec.Containers[i]
+		}
+	}
+	return nil
+}
+
 // handleBizBatchStart is a method of VPodProvider that handles the start of a container
 func (b *VPodProvider) handleBizBatchStart(ctx context.Context, pod *corev1.Pod, containers []corev1.Container) {
 	podKey := utils.GetPodKey(pod)
@@ -189,9 +367,16 @@ func (b *VPodProvider) handleBizBatchStart(ctx context.Context, pod *corev1.Pod,
 	}
 
 	for _, container := range containers {
+		var configErr error
 		err := tracker.G().FuncTrack(labelMap[model.LabelKeyOfTraceID], model.TrackSceneVPodDeploy, model.TrackEventContainerStart, labelMap, func() (error, model.ErrorCode) {
-			err := utils.CallWithRetry(ctx, func(_ int) (bool, error) {
-				innerErr := b.tunnel.StartBiz(b.nodeName, podKey, &container)
+			// the tunnel gets the container with its env resolved
+			resolved, err := b.resolveContainerEnv(ctx, pod, &container)
+			if err != nil {
+				configErr = err
+				return err, model.CodeContainerConfigError
+			}
+			err = utils.CallWithRetry(ctx, func(_ int) (bool, error) {
+				innerErr := b.tunnel.StartBiz(b.nodeName, podKey, resolved)
 
 				return innerErr != nil, innerErr
 			}, nil)
@@ -200,9 +385,62 @@ func (b *VPodProvider) handleBizBatchStart(ctx context.Context, pod *corev1.Pod,
 			}
 			return nil, model.CodeSuccess
 		})
+		if configErr != nil {
+			b.handleContainerConfigError(ctx, pod, container, configErr, func(pod *corev1.Pod, container corev1.Container) {
+				b.handleBizBatchStart(ctx, pod, []corev1.Container{container})
+			})
+			continue
+		}
 		if err != nil {
 			logger.WithError(err).WithField("containerKey", utils.GetContainerKey(podKey, container.Name)).Error("ContainerStartFailed")
 		}
+		b.watchStartTimeout(ctx, pod, container)
+	}
+}
+
+// handleBizBatchUpgrade is a method of VPodProvider that hot upgrades the biz of containers whose BIZ_VERSION is the only change,
+// a failed upgrade is recovered by the restart policy once the new biz is not activated before the start deadline
+func (b *VPodProvider) handleBizBatchUpgrade(ctx context.Context, pod *corev1.Pod, upgrades []bizUpgrade) {
+	podKey := utils.GetPodKey(pod)
+
+	logger := log.G(ctx).WithField("podKey", podKey)
+	logger.Info("HandleBizUpgradeOperation")
+
+	labelMap := pod.Labels
+	if labelMap == nil {
+		labelMap = make(map[string]string)
+	}
+
+	upgrader := b.tunnel.(tunnel.BizUpgrader)
+	for _, upgrade := range upgrades {
+		var configErr error
+		err := tracker.G().FuncTrack(labelMap[model.LabelKeyOfTraceID], model.TrackSceneVPodDeploy, model.TrackEventBizUpgrade, labelMap, func() (error, model.ErrorCode) {
+			resolved, err := b.resolveContainerEnv(ctx, pod, &upgrade.newContainer)
+			if err != nil {
+				configErr = err
+				return err, model.CodeContainerConfigError
+			}
+			err = utils.CallWithRetry(ctx, func(_ int) (bool, error) {
+				innerErr := upgrader.UpgradeBiz(b.nodeName, podKey, &upgrade.oldContainer, resolved)
+
+				return innerErr != nil, innerErr
+			}, nil)
+			if err != nil {
+				return err, model.CodeBizUpgradeFailed
+			}
+			return nil, model.CodeSuccess
+		})
+		if configErr != nil {
+			oldContainer := upgrade.oldContainer
+			b.handleContainerConfigError(ctx, pod, upgrade.newContainer, configErr, func(pod *corev1.Pod, container corev1.Container) {
+				b.handleBizBatchUpgrade(ctx, pod, []bizUpgrade{{oldContainer: oldContainer, newContainer: container}})
+			})
+			continue
+		}
+		if err != nil {
+			logger.WithError(err).WithField("containerKey", utils.GetContainerKey(podKey, upgrade.newContainer.Name)).Error("BizUpgradeFailed")
+		}
+		b.watchStartTimeout(ctx, pod, upgrade.newContainer)
 	}
 }
 
@@ -247,14 +485,100 @@ func (b *VPodProvider) CreatePod(ctx context.Context, pod *corev1.Pod) error {
 	logger := log.G(ctx).WithField("podKey", utils.GetPodKey(pod))
 	logger.Info("CreatePodStarted")
 
+	if reason, message := b.admitPod(ctx, pod); reason != "" {
+		logger.Warnf("CreatePodRejected: %s", message)
+		// keep the rejected pod in provider so it won't be created again, its resources are not counted as used
+		podCopy := pod.DeepCopy()
+		podCopy.Status.Phase = corev1.PodFailed
+		podCopy.Status.Reason = reason
+		podCopy.Status.Message = message
+		b.vPodStore.PutPod(podCopy)
+		b.notify(podCopy)
+		return nil
+	}
+
 	// update the baseline info so the async handle logic can see them first
 	podCopy := pod.DeepCopy()
 	b.vPodStore.PutPod(podCopy)
-	b.handleBizBatchStart(ctx, podCopy, podCopy.Spec.Containers)
+	// notified before starting, so the status synced by a failed start is not overwritten
 	b.notify(podCopy)
+	if record := interruptedUpdateOf(podCopy); record != nil {
+		// the pod in update is created again after a restart, the containers not changed by the update are started as usual
+		b.handleBizBatchStart(ctx, podCopy, b.recoverPodUpdate(ctx, podCopy, record))
+	} else {
+		b.handleBizBatchStart(ctx, podCopy, podCopy.Spec.Containers)
+	}
+	b.prober.addPod(ctx, podCopy)
 	return nil
 }
 
+// admitPod checks the resource requests of the pod against the allocatable of the vnode minus the requests of admitted vpods,
+// returns the reason and message of the rejection, empty reason means the pod is admitted
+func (b *VPodProvider) admitPod(ctx context.Context, pod *corev1.Pod) (string, string) {
+	if b.cache == nil {
+		return "", ""
+	}
+	node := &corev1.Node{}
+	err := b.cache.Get(ctx, types.NamespacedName{Name: b.nodeName}, node)
+	if err != nil {
+		log.G(ctx).WithError(err).Warnf("failed to get node %s, skip admission of pod %s", b.nodeName, utils.GetPodKey(pod))
+		return "", ""
+	}
+
+	podKey := utils.GetPodKey(pod)
+	usedMemory := resource.Quantity{}
+	usedBizSlots := int64(0)
+	for _, admittedPod := range b.vPodStore.GetPods() {
+		if utils.GetPodKey(admittedPod) == podKey || admittedPod.Status.Phase == corev1.PodFailed || admittedPod.Status.Phase == corev1.PodSucceeded {
+			continue
+		}
+		memory, bizSlots := b.podRequests(admittedPod)
+		usedMemory.Add(memory)
+		usedBizSlots += bizSlots
+	}
+
+	requestedMemory, requestedBizSlots := b.podRequests(pod)
+	if allocatable, has := node.Status.Allocatable[corev1.ResourceMemory]; has && !requestedMemory.IsZero() {
+		totalMemory := usedMemory.DeepCopy()
+		totalMemory.Add(requestedMemory)
+		if totalMemory.Cmp(allocatable) > 0 {
+			return model.PodReasonOutOfMemory, fmt.Sprintf("Node didn't have enough resource: %s, requested: %d, used: %d, capacity: %d",
+				corev1.ResourceMemory, requestedMemory.Value(), usedMemory.Value(), allocatable.Value())
+		}
+	}
+	if allocatable, has := node.Status.Allocatable[model.ResourceNameBizSlots]; has && usedBizSlots+requestedBizSlots > allocatable.Value() {
+		return model.PodReasonOutOfBizSlots, fmt.Sprintf("Node didn't have enough resource: %s, requested: %d, used: %d, capacity: %d",
+			model.ResourceNameBizSlots, requestedBizSlots, usedBizSlots, allocatable.Value())
+	}
+	return "", ""
+}
+
+// podRequests returns the memory and biz slots requested by the biz containers of the pod, a biz container takes one biz slot if not declared
+func (b *VPodProvider) podRequests(pod *corev1.Pod) (resource.Quantity, int64) {
+	memory := resource.Quantity{}
+	bizSlots := int64(0)
+	for _, container := range pod.Spec.Containers {
+		if !b.bizContainerMatcher.IsBizContainer(pod, &container) {
+			continue
+		}
+		if request, has := container.Resources.Requests[corev1.ResourceMemory]; has {
+			memory.Add(request)
+		}
+		if request, has := container.Resources.Requests[model.ResourceNameBizSlots]; has {
+			bizSlots += request.Value()
+		} else {
+			bizSlots++
+		}
+	}
+	return memory, bizSlots
+}
+
+// isRejectedPod checks whether the pod is rejected by admission, the biz of rejected pods are never started
+func isRejectedPod(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodFailed &&
+		(pod.Status.Reason == model.PodReasonOutOfMemory || pod.Status.Reason == model.PodReasonOutOfBizSlots)
+}
+
 // UpdatePod is a method of VPodProvider that updates a pod
 func (b *VPodProvider) UpdatePod(ctx context.Context, pod *corev1.Pod) error {
 	podKey := utils.GetPodKey(pod)
@@ -274,76 +598,39 @@ func (b *VPodProvider) UpdatePod(ctx context.Context, pod *corev1.Pod) error {
 		return pkgerrors.Errorf("pod %s not found when updating", podKey)
 	}
 
-	newContainerMap := make(map[string]corev1.Container)
-	oldContainerMap := make(map[string]corev1.Container)
-	for _, container := range newPod.Spec.Containers {
-		newContainerMap[container.Name] = container
-	}
-	for _, container := range oldPod.Spec.Containers {
-		oldContainerMap[container.Name] = container
+	// the update is recorded before stopping the old biz, so the stopped status reported at once is not missed
+	update, shouldStopContainers, shouldUpgradeBiz := b.planPodUpdate(oldPod, newPod)
+	if len(shouldStopContainers) > 0 {
+		b.prober.removeContainers(oldPod, shouldStopContainers)
+		b.handleBizBatchStop(ctx, oldPod, shouldStopContainers)
 	}
 
-	shouldStopContainers := make([]corev1.Container, 0)
-	shouldStartContainers := make([]corev1.Container, 0)
-	// find the container that updated in new pod
-	for name, oldContainer := range oldContainerMap {
-		if newContainer, has := newContainerMap[name]; has && !cmp.Equal(newContainer, oldContainer) {
-			shouldStopContainers = append(shouldStopContainers, oldContainer)
-			shouldStartContainers = append(shouldStartContainers, newContainer)
+	if isRestartedPod(oldPod, newPod) {
+		// the old instance of each reinstalled container is killed, the restart is counted once the new one is activated
+		for _, name := range update.startingContainers {
+			b.markTerminated(podKey, name)
 		}
 	}
-	// find the new container that not existed in old pod
-	for name, newContainer := range newContainerMap {
-		if _, has := oldContainerMap[name]; !has {
-			shouldStartContainers = append(shouldStartContainers, newContainer)
-		}
+	b.vPodStore.PutPod(newPod.DeepCopy())
+	b.prober.addPod(ctx, newPod)
+	// notified before starting, so the status synced by a failed start is not overwritten
+	b.notify(newPod)
+	if len(shouldUpgradeBiz) > 0 {
+		b.handleBizBatchUpgrade(ctx, newPod, shouldUpgradeBiz)
 	}
-	if len(shouldStopContainers) > 0 {
-		b.handleBizBatchStop(ctx, oldPod, shouldStopContainers)
+	suspendChanged := isSuspendedPod(oldPod) != isSuspendedPod(newPod)
+	if suspendChanged && isSuspendedPod(newPod) {
+		b.suspendPod(ctx, newPod)
+	} else if suspendChanged {
+		b.resumePod(ctx, newPod)
 	}
 
-	b.vPodStore.PutPod(newPod.DeepCopy())
+	b.runPodUpdate(ctx, newPod, update)
 
-	if len(shouldStartContainers) == 0 {
-		b.notify(newPod)
-		return nil
+	if suspendChanged || update.phase != podUpdatePhaseDone {
+		// the readiness of the containers follows the suspension, and the phase of the update in flight is persisted in the pod status
+		b.syncPodStatusToKube(ctx, podKey)
 	}
-
-	// only start new containers and changed containers
-	tracker.G().Eventually(pod.Labels[model.LabelKeyOfTraceID], model.TrackSceneVPodDeploy, model.TrackEventVPodUpdate, pod.Labels, model.CodeContainerStartTimeout, func(context.Context) (bool, error) {
-		podFromKubernetes := &corev1.Pod{}
-		err := b.client.Get(ctx, client.ObjectKey{
-			Namespace: pod.Namespace,
-			Name:      pod.Name,
-		}, podFromKubernetes)
-		if err != nil {
-			logger.WithError(err).Error("Failed to get pod from k8s")
-			// should failed if can't get pod from k8s
-			if errors.IsNotFound(err) {
-				// stop retry and no need to start new containers
-				return false, err
-			}
-			return false, nil
-		}
-
-		nameToContainerStatus := make(map[string]corev1.ContainerStatus)
-		for _, containerStatus := range podFromKubernetes.Status.ContainerStatuses {
-			nameToContainerStatus[containerStatus.Name] = containerStatus
-		}
-
-		for _, shouldUpdateContainer := range shouldStopContainers {
-			if status, has := nameToContainerStatus[shouldUpdateContainer.Name]; has && status.State.Terminated == nil {
-				return false, nil
-			}
-		}
-		return true, nil
-	}, time.Minute, time.Second, func() {
-		b.handleBizBatchStart(ctx, newPod, shouldStartContainers)
-	}, func() {
-		logger.Error("stop old containers timeout, not start new containers")
-	})
-
-	b.notify(newPod)
 	return nil
 }
 
@@ -357,14 +644,70 @@ func (b *VPodProvider) DeletePod(ctx context.Context, pod *corev1.Pod) error {
 		return nil
 	}
 
-	// delete from curr provider
-	b.vPodStore.DeletePod(podKey)
-	// should not stop biz when delete pod, it should stopped when pod
-	b.handleBizBatchStop(ctx, pod, pod.Spec.Containers)
-	b.notify(pod)
+	podFromProvider := b.vPodStore.GetPodByKey(podKey)
+	if (podFromProvider != nil && isRejectedPod(podFromProvider)) || pod.DeletionTimestamp == nil {
+		// biz of rejected pod never started, and pod without deletion timestamp is already removed from k8s, delete from curr provider immediately
+		runningContainers := b.runningContainersOf(pod)
+		b.vPodStore.DeletePod(podKey)
+		b.forgetRestarts(pod)
+		b.prober.removePod(pod)
+		if podFromProvider == nil || !isRejectedPod(podFromProvider) {
+			b.handleBizBatchStop(ctx, pod, runningContainers)
+		}
+		b.notify(pod)
+		return nil
+	}
+
+	// DeletePod may be called multiple times for the same pod
+	b.bizLock.Lock()
+	terminating := b.terminatingPods[pod.UID]
+	b.terminatingPods[pod.UID] = true
+	b.bizLock.Unlock()
+	if terminating {
+		return nil
+	}
+
+	terminatingPod := pod.DeepCopy()
+	if podFromProvider != nil {
+		terminatingPod = podFromProvider.DeepCopy()
+		terminatingPod.DeletionTimestamp = pod.DeletionTimestamp
+		terminatingPod.DeletionGracePeriodSeconds = pod.DeletionGracePeriodSeconds
+	}
+	b.vPodStore.PutPod(terminatingPod)
+	// the deletion timestamp is the end of the grace period, the biz is stopped gracefully without blocking the pod workers
+	go b.terminatePod(ctx, terminatingPod, pod.DeletionTimestamp.Time)
 	return nil
 }
 
+// StopOrphanBiz is a method of VPodProvider that stops the biz running in base whose vpod doesn't exist in k8s
+func (b *VPodProvider) StopOrphanBiz(ctx context.Context, bizKey string) error {
+	if b.isServingBiz(bizKey) {
+		// the old biz of a StartFirst update has no pod in k8s any more, but it keeps serving until the traffic is switched
+		return nil
+	}
+	container, err := b.bizKeyStrategy.ParseBizUniqueKey(bizKey)
+	if err != nil {
+		return err
+	}
+	// the pod name marks the biz has no pod, so it will be stopped without pod key
+	b.handleBizBatchStop(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      model.ObjectMetaNameNotExistPod,
+			Namespace: corev1.NamespaceDefault,
+		},
+	}, []corev1.Container{*container})
+	return nil
+}
+
+// StopAllBiz is a method of VPodProvider that stops the biz of all pods in provider, used when the vnode is decommissioned
+func (b *VPodProvider) StopAllBiz(ctx context.Context) {
+	for _, pod := range b.vPodStore.GetPods() {
+		b.vPodStore.DeletePod(utils.GetPodKey(pod))
+		b.prober.removePod(pod)
+		b.handleBizBatchStop(ctx, pod, pod.Spec.Containers)
+	}
+}
+
 // GetPod is a method of VPodProvider that gets a pod
 // This method is simply used to return the observed defaultPod by local
 //
@@ -376,44 +719,84 @@ func (b *VPodProvider) GetPod(_ context.Context, namespace, name string) (*corev
 
 // GetPodStatus is a method of VPodProvider that gets the status of a pod
 // This will be called repeatedly by virtual kubelet framework to get the defaultPod status
-// we should query the actual runtime info and convert them in to V1PodStatus accordingly
-func (b *VPodProvider) GetPodStatus(ctx context.Context, pod *corev1.Pod, bizStatus model.BizStatusData) (*corev1.PodStatus, error) {
+// the status is computed from the latest biz status of all containers recorded in the store,
+// containers without biz status keep their previous status
+func (b *VPodProvider) GetPodStatus(ctx context.Context, pod *corev1.Pod) (*corev1.PodStatus, error) {
 	podStatus := &corev1.PodStatus{}
 	// check pod status
 	bizJarContainerCount := 0
 	readyBizJarContainerCount := 0
 	terminatedBizJarContainerCount := 0
+	failedBizJarContainerCount := 0
 	notReadyBizJarContainerCount := 0
 	notInitedBizJarContainerCount := 0
 
-	podStatus.PodIP = b.localIP
-	podStatus.PodIPs = []corev1.PodIP{{IP: b.localIP}}
+	localIP := b.localIP.Load().(string)
+	podStatus.PodIP = localIP
+	podStatus.PodIPs = []corev1.PodIP{{IP: localIP}}
 	podStatus.ContainerStatuses = make([]corev1.ContainerStatus, 0)
 
 	nameToContainerStatus := make(map[string]*corev1.ContainerStatus)
 	for _, cs := range pod.Status.ContainerStatuses {
 		nameToContainerStatus[cs.Name] = &cs
 	}
+	nameToBizStatus := b.vPodStore.GetBizStatuses(utils.GetPodKey(pod))
+	nameToServingBiz := b.servingBizOf(utils.GetPodKey(pod))
+	nameToReinstallingBiz := b.reinstallingBizOf(pod)
 
 	// TODO: check all containers status only biz jar container
 	for _, container := range pod.Spec.Containers {
-		// only check biz jar container
-		if !strings.Contains(container.Image, ".jar") {
+		// only check biz container
+		if !b.bizContainerMatcher.IsBizContainer(pod, &container) {
 			continue
 		}
-		containerStatus, err := utils.ConvertBizStatusToContainerStatus(&container, nameToContainerStatus[container.Name], &bizStatus)
+		var bizStatus *model.BizStatusData
+		// the biz status of an old version of the container is ignored
+		if data, has := nameToBizStatus[container.Name]; has && data.Key == b.bizKeyStrategy.BizUniqueKey(pod, &container) {
+			bizStatus = &data
+		}
+		if reinstalling, has := nameToReinstallingBiz[container.Name]; has && reinstalling.Key == b.bizKeyStrategy.BizUniqueKey(pod, &container) {
+			// the old instance of the restarted biz is stopping
+			bizStatus = &reinstalling
+		}
+		mappedContainer := &container
+		serving, isServing := nameToServingBiz[container.Name]
+		if isServing {
+			// the old biz serves until the traffic is switched to the new version
+			mappedContainer = &serving.container
+			bizStatus = serving.status
+		}
+		containerStatus, err := b.statusMapper.MapBizStatus(mappedContainer, nameToContainerStatus[container.Name], bizStatus)
 		if err != nil || containerStatus == nil {
 			log.G(ctx).Errorf("can't convert biz status to container status for container %s", utils.GetContainerKey(utils.GetPodKey(pod), container.Name))
 			return nil, err
+		}
+		if pod.DeletionTimestamp != nil {
+			// terminating pod is never ready, and its biz is never restarted
+			containerStatus.Ready = false
+		} else if isServing {
+			b.prober.applyResults(utils.GetPodKey(pod), mappedContainer, containerStatus)
 		} else {
-			podStatus.ContainerStatuses = append(podStatus.ContainerStatuses, *containerStatus)
+			if bizStatus != nil && containerStatus.State.Waiting != nil && containerStatus.State.Waiting.Reason == model.ContainerReasonBizBroken {
+				b.applyRestartPolicy(pod, &container, containerStatus, *bizStatus)
+			}
+			b.prober.applyResults(utils.GetPodKey(pod), &container, containerStatus)
+			b.applyPostStart(pod, &container, containerStatus, bizStatus)
+			applySuspend(pod, containerStatus)
 		}
+		podStatus.ContainerStatuses = append(podStatus.ContainerStatuses, *containerStatus)
 
 		bizJarContainerCount++
 		if containerStatus.Ready {
 			readyBizJarContainerCount++
 		} else if containerStatus.State.Terminated != nil {
 			terminatedBizJarContainerCount++
+			if containerStatus.State.Terminated.ExitCode != 0 {
+				failedBizJarContainerCount++
+			}
+		} else if containerStatus.State.Waiting != nil && containerStatus.State.Waiting.Reason == model.ContainerReasonCreateContainerConfigError {
+			// never created, same as kubelet
+			notInitedBizJarContainerCount++
 		} else if containerStatus.State.Waiting != nil || containerStatus.State.Running != nil {
 			notReadyBizJarContainerCount++
 		} else {
@@ -427,6 +810,9 @@ func (b *VPodProvider) GetPodStatus(ctx context.Context, pod *corev1.Pod, bizSta
 	if bizJarContainerCount == 0 || bizJarContainerCount == terminatedBizJarContainerCount {
 		// if no biz jar container or all biz jar container terminated, pod is terminated
 		podStatus.Phase = corev1.PodSucceeded
+		if failedBizJarContainerCount > 0 {
+			podStatus.Phase = corev1.PodFailed
+		}
 		podStatus.Conditions = []corev1.PodCondition{
 			{
 				Type:          "Ready",
@@ -498,10 +884,222 @@ func (b *VPodProvider) GetPodStatus(ctx context.Context, pod *corev1.Pod, bizSta
 			},
 		}
 	}
+	if isSuspendedPod(pod) && podStatus.Phase == corev1.PodRunning {
+		for i := range podStatus.Conditions {
+			podStatus.Conditions[i].Reason = model.PodReasonSuspended
+			podStatus.Conditions[i].Message = "biz of the pod is deactivated"
+		}
+	}
+	if condition := b.podUpdateConditionOf(utils.GetPodKey(pod)); condition != nil {
+		podStatus.Conditions = append(podStatus.Conditions, *condition)
+	}
 
 	return podStatus, nil
 }
 
+// applyRestartPolicy applies the restart policy of the pod to the status of a broken biz container:
+// with Never the container is terminated, otherwise the container waits in CrashLoopBackOff until the biz is reinstalled by handleBrokenBiz
+func (b *VPodProvider) applyRestartPolicy(pod *corev1.Pod, container *corev1.Container, containerStatus *corev1.ContainerStatus, bizStatus model.BizStatusData) {
+	if pod.Spec.RestartPolicy == corev1.RestartPolicyNever {
+		containerStatus.State = containerStatus.LastTerminationState
+		containerStatus.LastTerminationState = corev1.ContainerState{}
+		return
+	}
+
+	podKey := utils.GetPodKey(pod)
+	reason := model.ContainerReasonCrashLoopBackOff
+	if bizStatus.Reason == model.ContainerReasonStartTimeout {
+		reason = model.ContainerReasonStartTimeout
+	}
+	containerStatus.State.Waiting = &corev1.ContainerStateWaiting{
+		Reason:  reason,
+		Message: fmt.Sprintf("back-off %s restarting failed container=%s pod=%s", b.restartBackoff.Get(utils.GetContainerKey(podKey, container.Name)), container.Name, podKey),
+	}
+}
+
+// handleBrokenBiz reinstalls the broken biz after an exponential backoff unless the pod never restarts, once for each broken status
+func (b *VPodProvider) handleBrokenBiz(ctx context.Context, pod *corev1.Pod, container *corev1.Container, bizStatus model.BizStatusData) {
+	if pod.Spec.RestartPolicy == corev1.RestartPolicyNever {
+		return
+	}
+
+	podKey := utils.GetPodKey(pod)
+	containerKey := utils.GetContainerKey(podKey, container.Name)
+	b.bizLock.Lock()
+	handled := b.restartedBiz[containerKey].Equal(bizStatus.ChangeTime)
+	if !handled {
+		b.restartedBiz[containerKey] = bizStatus.ChangeTime
+		b.restartBackoff.Next(containerKey, time.Now())
+	}
+	b.bizLock.Unlock()
+	if handled {
+		return
+	}
+
+	delay := b.restartBackoff.Get(containerKey)
+	log.G(ctx).Infof("biz %s broken, reinstall after %s", containerKey, delay)
+	containerName := container.Name
+	time.AfterFunc(delay, func() {
+		b.restartBrokenBiz(ctx, podKey, containerName, bizStatus)
+	})
+}
+
+// restartBrokenBiz reinstalls the broken biz if it is still the same broken one when the backoff expires
+func (b *VPodProvider) restartBrokenBiz(ctx context.Context, podKey, containerName string, bizStatus model.BizStatusData) {
+	pod := b.vPodStore.GetPodByKey(podKey)
+	if pod == nil || pod.DeletionTimestamp != nil {
+		return
+	}
+	latest, has := b.vPodStore.GetBizStatuses(podKey)[containerName]
+	if !has || latest.Key != bizStatus.Key || !latest.ChangeTime.Equal(bizStatus.ChangeTime) {
+		// the biz changed during backoff
+		return
+	}
+	container := containerOf(pod, containerName)
+	if container == nil || b.bizKeyStrategy.BizUniqueKey(pod, container) != bizStatus.Key {
+		return
+	}
+
+	log.G(ctx).Infof("reinstall broken biz %s", utils.GetContainerKey(podKey, containerName))
+	b.handleBizBatchStop(ctx, pod, []corev1.Container{*container})
+
+	// the status is recorded before starting so a config error is not overwritten
+	b.vPodStore.PutBizStatus(podKey, model.BizStatusData{
+		Key:        bizStatus.Key,
+		Name:       containerName,
+		PodKey:     podKey,
+		State:      string(model.BizStateResolved),
+		ChangeTime: time.Now(),
+		Reason:     model.ContainerReasonContainerCreating,
+	})
+	b.handleBizBatchStart(ctx, pod, []corev1.Container{*container})
+	b.syncPodStatusToKube(ctx, podKey)
+}
+
+// handleProbeFailure handles the failure of liveness or startup probe like kubelet killing the container,
+// the biz is taken as broken so it is reinstalled or terminated by the restart policy of the pod
+func (b *VPodProvider) handleProbeFailure(ctx context.Context, pod *corev1.Pod, container *corev1.Container, probeType, message string) {
+	log.G(ctx).Warnf("%s probe of %s failed: %s", probeType, utils.GetContainerKey(utils.GetPodKey(pod), container.Name), message)
+	if isSuspendedPod(pod) {
+		// the deactivated biz of suspended pod is not serving, it is not killed
+		return
+	}
+	b.breakBiz(ctx, pod, container, "", fmt.Sprintf("%s probe failed: %s", probeType, message), 137)
+}
+
+// breakBiz records the biz of the container as broken, so it is reinstalled or terminated by the restart policy of the pod
+func (b *VPodProvider) breakBiz(ctx context.Context, pod *corev1.Pod, container *corev1.Container, reason, message string, exitCode int32) {
+	podKey := utils.GetPodKey(pod)
+	b.recordBizStatus(ctx, podKey, model.BizStatusData{
+		Key:        b.bizKeyStrategy.BizUniqueKey(pod, container),
+		Name:       container.Name,
+		PodKey:     podKey,
+		State:      string(model.BizStateBroken),
+		ChangeTime: time.Now(),
+		Reason:     reason,
+		Message:    message,
+		ExitCode:   exitCode,
+	})
+	if pod.Spec.RestartPolicy == corev1.RestartPolicyNever {
+		// the biz won't be reinstalled, the broken status is recorded first so the stopped status is ignored
+		b.handleBizBatchStop(ctx, pod, []corev1.Container{*container})
+	}
+	b.syncPodStatusToKube(ctx, podKey)
+}
+
+// startTimeoutOf returns the deadline of the biz of the pod to be activated after started, non-positive means no timeout
+func (b *VPodProvider) startTimeoutOf(pod *corev1.Pod) time.Duration {
+	if value, has := pod.Annotations[model.AnnotationKeyOfStartTimeoutSeconds]; has {
+		seconds, err := strconv.Atoi(value)
+		if err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		log.L.Warnf("invalid %s of pod %s: %s", model.AnnotationKeyOfStartTimeoutSeconds, utils.GetPodKey(pod), value)
+	}
+	return b.bizStartTimeout
+}
+
+// watchStartTimeout starts the deadline of the start attempt of the biz container, other containers never time out
+func (b *VPodProvider) watchStartTimeout(ctx context.Context, pod *corev1.Pod, container corev1.Container) {
+	if !b.bizContainerMatcher.IsBizContainer(pod, &container) {
+		return
+	}
+	timeout := b.startTimeoutOf(pod)
+	if timeout <= 0 {
+		return
+	}
+	podKey := utils.GetPodKey(pod)
+	containerKey := utils.GetContainerKey(podKey, container.Name)
+	b.bizLock.Lock()
+	b.startAttempts[containerKey]++
+	attempt := b.startAttempts[containerKey]
+	b.bizLock.Unlock()
+
+	bizKey := b.bizKeyStrategy.BizUniqueKey(pod, &container)
+	time.AfterFunc(timeout, func() {
+		b.checkStartTimeout(ctx, podKey, container.Name, bizKey, attempt, timeout)
+	})
+}
+
+// checkStartTimeout handles the biz not activated before the deadline of the start attempt, it is taken as broken with reason StartTimeout,
+// so it is reinstalled or terminated by the restart policy of the pod
+func (b *VPodProvider) checkStartTimeout(ctx context.Context, podKey, containerName, bizKey string, attempt int, timeout time.Duration) {
+	containerKey := utils.GetContainerKey(podKey, containerName)
+	b.bizLock.Lock()
+	latestAttempt := b.startAttempts[containerKey]
+	b.bizLock.Unlock()
+	if latestAttempt != attempt {
+		return
+	}
+
+	pod := b.vPodStore.GetPodByKey(podKey)
+	if pod == nil || pod.DeletionTimestamp != nil {
+		return
+	}
+	var container *corev1.Container
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == containerName {
+			container = &pod.Spec.Containers[i]
+		}
+	}
+	if container == nil || b.bizKeyStrategy.BizUniqueKey(pod, container) != bizKey {
+		return
+	}
+	if bizStatus, has := b.vPodStore.GetBizStatuses(podKey)[containerName]; has && bizStatus.Key == bizKey {
+		switch model.BizState(strings.ToUpper(bizStatus.State)) {
+		case model.BizStateActivated, model.BizStateBroken, model.BizStateStopped:
+			// started, or failed which is handled by restart policy
+			return
+		}
+	}
+
+	message := fmt.Sprintf("biz %s is not activated in %s", bizKey, timeout)
+	log.G(ctx).Warnf("container %s start timeout: %s", containerKey, message)
+	labelMap := pod.Labels
+	if labelMap == nil {
+		labelMap = make(map[string]string)
+	}
+	tracker.G().ErrorReport(labelMap[model.LabelKeyOfTraceID], model.TrackSceneVPodDeploy, model.TrackEventContainerStart, message, labelMap, model.CodeContainerStartTimeout)
+
+	b.breakBiz(ctx, pod, container, model.ContainerReasonStartTimeout, message, 1)
+}
+
+// forgetRestarts drops the restart backoff, terminations, start attempts, postStart hooks and suspension of the containers of the pod
+func (b *VPodProvider) forgetRestarts(pod *corev1.Pod) {
+	podKey := utils.GetPodKey(pod)
+	b.bizLock.Lock()
+	defer b.bizLock.Unlock()
+	for _, container := range pod.Spec.Containers {
+		containerKey := utils.GetContainerKey(podKey, container.Name)
+		delete(b.restartedBiz, containerKey)
+		delete(b.terminatedBiz, containerKey)
+		delete(b.startAttempts, containerKey)
+		delete(b.postStartHooks, containerKey)
+		delete(b.suspendedBiz, containerKey)
+		b.restartBackoff.DeleteEntry(containerKey)
+	}
+}
+
 func (b *VPodProvider) GetPods(_ context.Context) ([]*corev1.Pod, error) {
 	return b.vPodStore.GetPods(), nil
 }
@@ -2,21 +2,31 @@ package provider
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/koupleless/virtual-kubelet/common/utils"
 	"github.com/koupleless/virtual-kubelet/model"
 	"github.com/koupleless/virtual-kubelet/tunnel"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
 	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func TestSyncRelatedPodStatus(t *testing.T) {
-	provider := NewVPodProvider("default", "127.0.0.1", "123", nil, nil, &tunnel.MockTunnel{})
-	provider.syncBizStatusToKube(context.TODO(), model.BizStatusData{
+	provider, _ := newTestVPodProvider(t, nil, nil)
+	provider.vPodStore.PutBizStatus("test-pod-key", model.BizStatusData{
 		Key:        "test-biz-key",
 		Name:       "test-name",
 		PodKey:     "test-pod-key",
@@ -25,11 +35,13 @@ func TestSyncRelatedPodStatus(t *testing.T) {
 		Reason:     "test-reason",
 		Message:    "test-message",
 	})
+	provider.syncPodStatusToKube(context.TODO(), "test-pod-key")
 }
 
 func TestSyncAllContainerInfo(t *testing.T) {
 	tl := &tunnel.MockTunnel{}
-	provider := NewVPodProvider("default", "127.0.0.1", "123", nil, &informertest.FakeInformers{}, tl)
+	provider, _ := newTestVPodProvider(t, tl, nil)
+	provider.cache = &informertest.FakeInformers{}
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			CreationTimestamp: metav1.Time{Time: time.Now()},
@@ -61,7 +73,7 @@ func TestSyncAllContainerInfo(t *testing.T) {
 	}
 	provider.SyncAllBizStatusToKube(context.TODO(), []model.BizStatusData{
 		{
-			Key: tl.GetBizUniqueKey(&corev1.Container{
+			Key: utils.GetBizUniqueKey(&corev1.Container{
 				Name: "test-container",
 			}),
 			PodKey: "namespace/name",
@@ -70,8 +82,7 @@ func TestSyncAllContainerInfo(t *testing.T) {
 }
 
 func TestUpdateDeletedPod(t *testing.T) {
-	tl := &tunnel.MockTunnel{}
-	provider := NewVPodProvider("default", "127.0.0.1", "123", nil, nil, tl)
+	provider, _ := newTestVPodProvider(t, nil, nil)
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			CreationTimestamp: metav1.Time{Time: time.Now()},
@@ -91,9 +102,7 @@ func TestUpdateDeletedPod(t *testing.T) {
 }
 
 func TestDeletedPodNotExist(t *testing.T) {
-	tl := &tunnel.MockTunnel{}
-	provider := NewVPodProvider("default", "127.0.0.1", "123", nil, nil, tl)
-	provider.notify = func(pod *corev1.Pod) {}
+	provider, _ := newTestVPodProvider(t, nil, nil)
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:              model.ObjectMetaNameNotExistPod,
@@ -114,14 +123,6 @@ func TestDeletedPodNotExist(t *testing.T) {
 }
 
 func TestUpdatePod(t *testing.T) {
-	tl := &tunnel.MockTunnel{}
-	_ = tl.Start("test", "test")
-	tl.RegisterCallback(
-		func(info model.NodeInfo) {},
-		func(s string, data model.NodeStatusData) {},
-		func(s string, data []model.BizStatusData) {},
-		func(s string, data model.BizStatusData) {},
-	)
 	oldPod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:              "test",
@@ -139,12 +140,7 @@ func TestUpdatePod(t *testing.T) {
 	}
 	fakeCli := fake.NewFakeClient(oldPod)
 
-	provider := NewVPodProvider("default", "127.0.0.1", "123", fakeCli, nil, tl)
-
-	newPodCh := make(chan *corev1.Pod, 1)
-	provider.NotifyPods(context.Background(), func(pod *corev1.Pod) {
-		newPodCh <- pod
-	})
+	provider, notified := newTestVPodProvider(t, nil, fakeCli)
 	provider.vPodStore.podKeyToPod = map[string]*corev1.Pod{
 		"default/test": oldPod,
 	}
@@ -160,6 +156,420 @@ func TestUpdatePod(t *testing.T) {
 	err := provider.UpdatePod(context.TODO(), podToUpdate)
 	assert.NoError(t, err)
 
-	revdPod := <-newPodCh
+	revdPod := <-notified
 	assert.Len(t, revdPod.Spec.Containers, 2)
 }
+
+func TestCreatePod_Admission(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceMemory:      resource.MustParse("1Gi"),
+				model.ResourceNameBizSlots: resource.MustParse("2"),
+			},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithObjects(node).Build()
+	provider, _ := newTestVPodProvider(t, nil, fakeClient)
+	notified := make(map[string]*corev1.Pod)
+	provider.notify = func(pod *corev1.Pod) {
+		notified[pod.Name] = pod
+	}
+	buildPod := func(name string, memory string, containerCount int) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		}
+		for i := 0; i < containerCount; i++ {
+			pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+				Name:  name + "-" + string(rune('a'+i)),
+				Image: name + ".jar",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(memory)},
+				},
+			})
+		}
+		return pod
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, provider.CreatePod(ctx, buildPod("pod-1", "512Mi", 1)))
+	assert.NotEqual(t, corev1.PodFailed, notified["pod-1"].Status.Phase)
+
+	assert.NoError(t, provider.CreatePod(ctx, buildPod("pod-2", "1Gi", 1)))
+	assert.Equal(t, corev1.PodFailed, notified["pod-2"].Status.Phase)
+	assert.Equal(t, model.PodReasonOutOfMemory, notified["pod-2"].Status.Reason)
+	assert.NotNil(t, provider.vPodStore.GetPodByKey("default/pod-2"))
+
+	assert.NoError(t, provider.CreatePod(ctx, buildPod("pod-3", "1Mi", 2)))
+	assert.Equal(t, corev1.PodFailed, notified["pod-3"].Status.Phase)
+	assert.Equal(t, model.PodReasonOutOfBizSlots, notified["pod-3"].Status.Reason)
+
+	// rejected pods don't take resources, and non-biz containers are not counted
+	pod := buildPod("pod-4", "256Mi", 1)
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+		Name:  "sidecar",
+		Image: "sidecar:latest",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+		},
+	})
+	assert.NoError(t, provider.CreatePod(ctx, pod))
+	assert.NotEqual(t, corev1.PodFailed, notified["pod-4"].Status.Phase)
+
+	assert.NoError(t, provider.DeletePod(ctx, buildPod("pod-2", "1Gi", 1)))
+	assert.Nil(t, provider.vPodStore.GetPodByKey("default/pod-2"))
+}
+
+func TestSyncAllBizStatusToKube_OncePerPod(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "biz1", Image: "biz1.jar", Env: []corev1.EnvVar{{Name: "BIZ_VERSION", Value: "0.0.1"}}},
+				{Name: "biz2", Image: "biz2.jar", Env: []corev1.EnvVar{{Name: "BIZ_VERSION", Value: "0.0.1"}}},
+			},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithObjects(pod).Build()
+	provider, notified := newTestVPodProvider(t, nil, fakeClient)
+	provider.vPodStore.PutPod(pod.DeepCopy())
+
+	changeTime := time.Date(2024, 1, 1, 0, 0, 30, 0, time.UTC)
+	bizStatusDatas := make([]model.BizStatusData, 0)
+	for _, container := range pod.Spec.Containers {
+		bizStatusDatas = append(bizStatusDatas, model.BizStatusData{
+			Key:        utils.DefaultBizKeyStrategy.BizUniqueKey(pod, &container),
+			Name:       container.Name,
+			PodKey:     "default/test-pod",
+			State:      string(model.BizStateActivated),
+			ChangeTime: changeTime,
+		})
+	}
+	provider.SyncAllBizStatusToKube(context.TODO(), bizStatusDatas)
+
+	assert.Len(t, notified, 1)
+	synced := <-notified
+	assert.Equal(t, corev1.PodRunning, synced.Status.Phase)
+	assert.Len(t, synced.Status.ContainerStatuses, 2)
+	for _, containerStatus := range synced.Status.ContainerStatuses {
+		assert.True(t, containerStatus.Ready)
+	}
+
+	// the status of an old version is not applied to the updated container
+	podCopy := provider.vPodStore.GetPodByKey("default/test-pod").DeepCopy()
+	podCopy.Spec.Containers[1].Env[0].Value = "0.0.2"
+	provider.vPodStore.PutPod(podCopy)
+	podStatus, err := provider.GetPodStatus(context.TODO(), podCopy)
+	assert.NoError(t, err)
+	assert.True(t, podStatus.ContainerStatuses[0].Ready)
+	assert.Equal(t, podCopy.Status.ContainerStatuses[1], podStatus.ContainerStatuses[1])
+}
+
+func TestBrokenBiz_RestartPolicy(t *testing.T) {
+	provider, notified := newTestVPodProvider(t, nil, nil)
+	provider.restartBackoff = flowcontrol.NewBackOff(10*time.Millisecond, 100*time.Millisecond)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyAlways,
+			Containers: []corev1.Container{
+				{Name: "biz1", Image: "biz1.jar", Env: []corev1.EnvVar{{Name: "BIZ_VERSION", Value: "0.0.1"}}},
+			},
+		},
+	}
+	provider.vPodStore.PutPod(pod.DeepCopy())
+	provider.recordBizStatus(context.TODO(), "default/test-pod", model.BizStatusData{
+		Key:        utils.DefaultBizKeyStrategy.BizUniqueKey(pod, &pod.Spec.Containers[0]),
+		Name:       "biz1",
+		PodKey:     "default/test-pod",
+		State:      string(model.BizStateBroken),
+		ChangeTime: time.Now(),
+	})
+	provider.syncPodStatusToKube(context.TODO(), "default/test-pod")
+
+	crashed := <-notified
+	assert.Equal(t, corev1.PodRunning, crashed.Status.Phase)
+	assert.Equal(t, model.ContainerReasonCrashLoopBackOff, crashed.Status.ContainerStatuses[0].State.Waiting.Reason)
+	assert.NotNil(t, crashed.Status.ContainerStatuses[0].LastTerminationState.Terminated)
+
+	// the broken biz is reinstalled after backoff, the restart is counted once it is activated
+	restarted := <-notified
+	assert.Equal(t, int32(0), restarted.Status.ContainerStatuses[0].RestartCount)
+	assert.Equal(t, model.ContainerReasonContainerCreating, restarted.Status.ContainerStatuses[0].State.Waiting.Reason)
+	activated := model.BizStatusData{
+		Key:        utils.DefaultBizKeyStrategy.BizUniqueKey(pod, &pod.Spec.Containers[0]),
+		Name:       "biz1",
+		PodKey:     "default/test-pod",
+		State:      string(model.BizStateActivated),
+		ChangeTime: time.Now(),
+	}
+	provider.recordBizStatus(context.TODO(), "default/test-pod", activated)
+	provider.syncPodStatusToKube(context.TODO(), "default/test-pod")
+	running := <-notified
+	assert.Equal(t, int32(1), running.Status.ContainerStatuses[0].RestartCount)
+	assert.NotNil(t, running.Status.ContainerStatuses[0].State.Running)
+
+	// the same activation is counted once
+	activated.Message = "synced again"
+	provider.recordBizStatus(context.TODO(), "default/test-pod", activated)
+	provider.syncPodStatusToKube(context.TODO(), "default/test-pod")
+	assert.Equal(t, int32(1), (<-notified).Status.ContainerStatuses[0].RestartCount)
+
+	// the broken biz of pod never restarting is terminated
+	pod.Name = "test-pod-never"
+	pod.Spec.RestartPolicy = corev1.RestartPolicyNever
+	provider.vPodStore.PutPod(pod.DeepCopy())
+	provider.recordBizStatus(context.TODO(), "default/test-pod-never", model.BizStatusData{
+		Key:        utils.DefaultBizKeyStrategy.BizUniqueKey(pod, &pod.Spec.Containers[0]),
+		Name:       "biz1",
+		PodKey:     "default/test-pod-never",
+		State:      string(model.BizStateBroken),
+		ChangeTime: time.Now(),
+	})
+	provider.syncPodStatusToKube(context.TODO(), "default/test-pod-never")
+
+	failed := <-notified
+	assert.Equal(t, corev1.PodFailed, failed.Status.Phase)
+	assert.Equal(t, int32(1), failed.Status.ContainerStatuses[0].State.Terminated.ExitCode)
+}
+
+func TestBrokenBiz_RecoveredByBase(t *testing.T) {
+	provider, notified := newTestVPodProvider(t, nil, nil)
+	provider.restartBackoff = flowcontrol.NewBackOff(time.Hour, time.Hour)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "biz1", Image: "biz1.jar", Env: []corev1.EnvVar{{Name: "BIZ_VERSION", Value: "0.0.1"}}},
+			},
+		},
+	}
+	provider.vPodStore.PutPod(pod.DeepCopy())
+	now := time.Now()
+	for i, state := range []model.BizState{model.BizStateActivated, model.BizStateBroken, model.BizStateActivated} {
+		provider.recordBizStatus(context.TODO(), "default/test-pod", model.BizStatusData{
+			Key:        "biz1:0.0.1",
+			Name:       "biz1",
+			PodKey:     "default/test-pod",
+			State:      string(state),
+			ChangeTime: now.Add(time.Duration(i) * time.Second),
+		})
+		provider.syncPodStatusToKube(context.TODO(), "default/test-pod")
+		<-notified
+	}
+
+	// the broken biz activated again by the base without reinstalling is restarted as well
+	containerStatus := provider.vPodStore.GetPodByKey("default/test-pod").Status.ContainerStatuses[0]
+	assert.NotNil(t, containerStatus.State.Running)
+	assert.NotNil(t, containerStatus.LastTerminationState.Terminated)
+	assert.Equal(t, int32(1), containerStatus.RestartCount)
+}
+
+func TestStartTimeout(t *testing.T) {
+	provider, notified := newTestVPodProvider(t, nil, nil)
+	// the biz never times out unless the timeout is set
+	assert.Equal(t, time.Duration(0), provider.startTimeoutOf(&corev1.Pod{}))
+	provider.SetBizStartTimeout(50 * time.Millisecond)
+	provider.restartBackoff = flowcontrol.NewBackOff(time.Hour, time.Hour)
+	buildPod := func(name string, restartPolicy corev1.RestartPolicy) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: corev1.PodSpec{
+				RestartPolicy: restartPolicy,
+				Containers: []corev1.Container{
+					{Name: "biz1", Image: "biz1.jar", Env: []corev1.EnvVar{{Name: "BIZ_VERSION", Value: "0.0.1"}}},
+				},
+			},
+		}
+	}
+
+	ctx := context.Background()
+	pod := buildPod("test-pod", corev1.RestartPolicyAlways)
+	assert.NoError(t, provider.CreatePod(ctx, pod))
+	<-notified
+	timeout := <-notified
+	assert.Equal(t, model.ContainerReasonStartTimeout, timeout.Status.ContainerStatuses[0].State.Waiting.Reason)
+	assert.NoError(t, provider.DeletePod(ctx, pod))
+	<-notified
+
+	pod = buildPod("test-pod-never", corev1.RestartPolicyNever)
+	assert.NoError(t, provider.CreatePod(ctx, pod))
+	<-notified
+	failed := <-notified
+	assert.Equal(t, corev1.PodFailed, failed.Status.Phase)
+	assert.Equal(t, model.ContainerReasonStartTimeout, failed.Status.ContainerStatuses[0].State.Terminated.Reason)
+
+	pod.Annotations = map[string]string{model.AnnotationKeyOfStartTimeoutSeconds: "0"}
+	assert.Equal(t, time.Duration(0), provider.startTimeoutOf(pod))
+	pod.Annotations[model.AnnotationKeyOfStartTimeoutSeconds] = "invalid"
+	assert.Equal(t, 50*time.Millisecond, provider.startTimeoutOf(pod))
+
+	// the container not running biz never times out
+	provider.watchStartTimeout(ctx, pod, corev1.Container{Name: "sidecar", Image: "sidecar"})
+	provider.bizLock.Lock()
+	assert.NotContains(t, provider.startAttempts, utils.GetContainerKey("default/test-pod-never", "sidecar"))
+	provider.bizLock.Unlock()
+}
+
+func TestDeletePod_Graceful(t *testing.T) {
+	preStopCalled := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		preStopCalled <- r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverURL.Port())
+
+	tl := &tunnel.MockTunnel{}
+	provider, notified := newTestVPodProvider(t, tl, nil)
+	tl.RegisterCallback(
+		func(info model.NodeInfo) {},
+		func(s string, data model.NodeStatusData) {},
+		func(s string, data []model.BizStatusData) {},
+		func(s string, data model.BizStatusData) {
+			provider.recordBizStatus(context.TODO(), data.PodKey, data)
+		},
+	)
+	buildPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+			Spec: corev1.PodSpec{
+				RestartPolicy: corev1.RestartPolicyAlways,
+				Containers: []corev1.Container{
+					{
+						Name:  "biz1",
+						Image: "biz1.jar",
+						Env:   []corev1.EnvVar{{Name: "BIZ_VERSION", Value: "0.0.1"}},
+						Lifecycle: &corev1.Lifecycle{
+							PreStop: &corev1.LifecycleHandler{
+								HTTPGet: &corev1.HTTPGetAction{Path: "/biz1/offline", Port: intstr.FromInt32(int32(port))},
+							},
+						},
+					},
+					{
+						// the preStop hook of the container not running biz is never run
+						Name:  "sidecar",
+						Image: "sidecar",
+						Lifecycle: &corev1.Lifecycle{
+							PreStop: &corev1.LifecycleHandler{
+								HTTPGet: &corev1.HTTPGetAction{Path: "/sidecar/offline", Port: intstr.FromInt32(int32(port))},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	waitTerminal := func() *corev1.Pod {
+		for {
+			select {
+			case pod := <-notified:
+				if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+					return pod
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("pod is not terminated")
+				return nil
+			}
+		}
+	}
+
+	ctx := context.Background()
+	pod := buildPod("test-pod")
+	assert.NoError(t, provider.CreatePod(ctx, pod))
+	pod.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(5 * time.Second)}
+	assert.NoError(t, provider.DeletePod(ctx, pod))
+	// deleting a terminating pod again is a no-op
+	assert.NoError(t, provider.DeletePod(ctx, pod))
+	assert.Equal(t, "/biz1/offline", <-preStopCalled)
+	succeeded := waitTerminal()
+	assert.Equal(t, corev1.PodSucceeded, succeeded.Status.Phase)
+	assert.Equal(t, int32(0), succeeded.Status.ContainerStatuses[0].State.Terminated.ExitCode)
+	assert.Nil(t, provider.vPodStore.GetPodByKey("default/test-pod"))
+
+	// the biz is never reported stopped before the grace period
+	tl.RegisterCallback(
+		func(info model.NodeInfo) {},
+		func(s string, data model.NodeStatusData) {},
+		func(s string, data []model.BizStatusData) {},
+		func(s string, data model.BizStatusData) {},
+	)
+	pod = buildPod("test-pod-killed")
+	assert.NoError(t, provider.CreatePod(ctx, pod))
+	pod.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(200 * time.Millisecond)}
+	assert.NoError(t, provider.DeletePod(ctx, pod))
+	<-preStopCalled
+	killed := waitTerminal()
+	assert.Equal(t, corev1.PodFailed, killed.Status.Phase)
+	assert.Equal(t, int32(137), killed.Status.ContainerStatuses[0].State.Terminated.ExitCode)
+	assert.Empty(t, preStopCalled)
+
+	// the pod recreated with the same name during the termination is kept
+	pod = buildPod("test-pod-killed")
+	assert.NoError(t, provider.CreatePod(ctx, pod))
+	pod.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(200 * time.Millisecond)}
+	assert.NoError(t, provider.DeletePod(ctx, pod))
+	<-preStopCalled
+	recreated := buildPod("test-pod-killed")
+	recreated.UID = "test-pod-recreated"
+	assert.NoError(t, provider.CreatePod(ctx, recreated))
+	assert.Eventually(t, func() bool {
+		provider.bizLock.Lock()
+		defer provider.bizLock.Unlock()
+		return !provider.terminatingPods[pod.UID]
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, recreated.UID, provider.vPodStore.GetPodByKey("default/test-pod-killed").UID)
+}
+
+func TestPostStartHook(t *testing.T) {
+	warmedUp := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/biz1/warmup" {
+			<-warmedUp
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverURL.Port())
+
+	provider, notified := newTestVPodProvider(t, nil, nil)
+	recorder := record.NewFakeRecorder(10)
+	provider.SetEventRecorder(recorder)
+	buildPod := func(path string) *corev1.Pod {
+		pod := buildProbedPod(nil, false)
+		pod.Spec.Containers[0].Lifecycle = &corev1.Lifecycle{
+			PostStart: &corev1.LifecycleHandler{
+				HTTPGet: &corev1.HTTPGetAction{Path: path, Port: intstr.FromInt32(int32(port))},
+			},
+		}
+		return pod
+	}
+
+	pod := buildPod("/biz1/warmup")
+	startProbedPod(provider, pod)
+	activated := <-notified
+	assert.Equal(t, corev1.PodRunning, activated.Status.Phase)
+	assert.False(t, activated.Status.ContainerStatuses[0].Ready)
+	close(warmedUp)
+	ready := <-notified
+	assert.True(t, ready.Status.ContainerStatuses[0].Ready)
+	provider.forgetRestarts(pod)
+
+	pod = buildPod("/biz1/fail")
+	pod.Spec.RestartPolicy = corev1.RestartPolicyNever
+	startProbedPod(provider, pod)
+	<-notified
+	failed := <-notified
+	assert.Equal(t, corev1.PodFailed, failed.Status.Phase)
+	terminated := failed.Status.ContainerStatuses[0].State.Terminated
+	assert.Equal(t, int32(137), terminated.ExitCode)
+	assert.Equal(t, model.ContainerReasonFailedPostStartHook, terminated.Reason)
+	assert.Contains(t, <-recorder.Events, model.ContainerReasonFailedPostStartHook)
+}
@@ -28,13 +28,20 @@ import (
 type VPodStore struct {
 	sync.RWMutex // This mutex is used for thread-safe access to the store.
 
-	podKeyToPod map[string]*corev1.Pod // Maps pod keys to their corresponding pods from provider
+	podKeyToPod       map[string]*corev1.Pod                    // Maps pod keys to their corresponding pods from provider
+	podKeyToBizStatus map[string]map[string]model.BizStatusData // Maps pod keys to the latest biz status of each container name
+	podKeyToUpdate    map[string]podUpdate                      // Maps pod keys to the state of their latest in-place update
+
+	bizContainerMatcher model.BizContainerMatcher // Decides which containers are biz modules
 }
 
 func NewVPodStore() *VPodStore {
 	return &VPodStore{
-		RWMutex:     sync.RWMutex{},
-		podKeyToPod: make(map[string]*corev1.Pod),
+		RWMutex:             sync.RWMutex{},
+		podKeyToPod:         make(map[string]*corev1.Pod),
+		podKeyToBizStatus:   make(map[string]map[string]model.BizStatusData),
+		podKeyToUpdate:      make(map[string]podUpdate),
+		bizContainerMatcher: utils.DefaultBizContainerMatcher,
 	}
 }
 
@@ -47,6 +54,19 @@ func (r *VPodStore) PutPod(pod *corev1.Pod) {
 
 	// create or update
 	r.podKeyToPod[podKey] = pod
+
+	// drop the biz status of containers removed from the pod
+	if bizStatuses, has := r.podKeyToBizStatus[podKey]; has {
+		containerNames := make(map[string]bool)
+		for _, container := range pod.Spec.Containers {
+			containerNames[container.Name] = true
+		}
+		for name := range bizStatuses {
+			if !containerNames[name] {
+				delete(bizStatuses, name)
+			}
+		}
+	}
 }
 
 // DeletePod function removes a pod from the VPodStore.
@@ -55,6 +75,90 @@ func (r *VPodStore) DeletePod(podKey string) {
 	defer r.Unlock()
 
 	delete(r.podKeyToPod, podKey)
+	delete(r.podKeyToBizStatus, podKey)
+	delete(r.podKeyToUpdate, podKey)
+}
+
+// PutPodUpdate function records the state of the latest in-place update of the pod.
+func (r *VPodStore) PutPodUpdate(podKey string, update podUpdate) {
+	r.Lock()
+	defer r.Unlock()
+
+	if _, has := r.podKeyToPod[podKey]; !has {
+		// the pod is deleted during the update
+		return
+	}
+	r.podKeyToUpdate[podKey] = update
+}
+
+// GetPodUpdate function retrieves the state of the latest in-place update of the pod.
+func (r *VPodStore) GetPodUpdate(podKey string) (podUpdate, bool) {
+	r.RLock()
+	defer r.RUnlock()
+
+	update, has := r.podKeyToUpdate[podKey]
+	return update, has
+}
+
+// GetPodUpdates function retrieves a copy of the state of the latest in-place update of all pods, keyed by pod key.
+func (r *VPodStore) GetPodUpdates() map[string]podUpdate {
+	r.RLock()
+	defer r.RUnlock()
+
+	ret := make(map[string]podUpdate, len(r.podKeyToUpdate))
+	for podKey, update := range r.podKeyToUpdate {
+		ret[podKey] = update
+	}
+	return ret
+}
+
+// PutBizStatus function records the latest biz status of a container of the pod,
+// the status older than the recorded one of the same biz, or after it terminated in a pod never restarting, is ignored and false is returned.
+func (r *VPodStore) PutBizStatus(podKey string, bizStatusData model.BizStatusData) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	bizStatuses, has := r.podKeyToBizStatus[podKey]
+	if !has {
+		bizStatuses = make(map[string]model.BizStatusData)
+		r.podKeyToBizStatus[podKey] = bizStatuses
+	}
+	if existing, has := bizStatuses[bizStatusData.Name]; has && existing.Key == bizStatusData.Key {
+		if existing.ChangeTime.After(bizStatusData.ChangeTime) {
+			return false
+		}
+		// same as kubelet, terminated containers of pods never restarting stay terminated
+		if pod := r.podKeyToPod[podKey]; pod != nil && pod.Spec.RestartPolicy == corev1.RestartPolicyNever && isTerminalBizState(existing.State) {
+			return false
+		}
+	}
+	bizStatuses[bizStatusData.Name] = bizStatusData
+	return true
+}
+
+// ResetBizStatus function records the status of a container whose biz is reinstalled, replacing the recorded one even if it is terminated.
+func (r *VPodStore) ResetBizStatus(podKey string, bizStatusData model.BizStatusData) {
+	r.Lock()
+	defer r.Unlock()
+
+	bizStatuses, has := r.podKeyToBizStatus[podKey]
+	if !has {
+		bizStatuses = make(map[string]model.BizStatusData)
+		r.podKeyToBizStatus[podKey] = bizStatuses
+	}
+	bizStatuses[bizStatusData.Name] = bizStatusData
+}
+
+// GetBizStatuses function retrieves a copy of the latest biz status of the pod, keyed by container name.
+func (r *VPodStore) GetBizStatuses(podKey string) map[string]model.BizStatusData {
+	r.RLock()
+	defer r.RUnlock()
+
+	ret := make(map[string]model.BizStatusData, len(r.podKeyToBizStatus[podKey]))
+	for name, bizStatusData := range r.podKeyToBizStatus[podKey] {
+		ret[name] = bizStatusData
+	}
+	return ret
 }
 
 // GetPodByKey function retrieves a pod by its key.
@@ -82,16 +186,18 @@ func (r *VPodStore) CheckContainerStatusNeedSync(pod *corev1.Pod, bizStatusData
 
 	var matchedStatus *corev1.ContainerStatus
 	var matchedContainer *corev1.Container
-	for _, status := range pod.Status.ContainerStatuses {
-		if (status.Name == bizStatusData.Name) && strings.Contains(status.Image, ".jar") {
-			matchedStatus = &status
-		}
-	}
 	for _, container := range pod.Spec.Containers {
 		if container.Name == bizStatusData.Name {
 			matchedContainer = &container
 		}
 	}
+	if matchedContainer != nil && r.bizContainerMatcher.IsBizContainer(pod, matchedContainer) {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == bizStatusData.Name {
+				matchedStatus = &status
+			}
+		}
+	}
 
 	// the earliest change time of the container status when no time
 	oldChangeTime := time.Time{}
@@ -117,3 +223,12 @@ func (r *VPodStore) CheckContainerStatusNeedSync(pod *corev1.Pod, bizStatusData
 		return false
 	}
 }
+
+// isTerminalBizState checks whether the biz is broken or stopped
+func isTerminalBizState(state string) bool {
+	switch model.BizState(strings.ToUpper(state)) {
+	case model.BizStateBroken, model.BizStateStopped:
+		return true
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"github.com/koupleless/virtual-kubelet/model"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -85,3 +86,34 @@ func TestVPodStore_GetPods(t *testing.T) {
 	ps := store.GetPods()
 	assert.Len(t, ps, 1)
 }
+
+func TestVPodStore_BizStatuses(t *testing.T) {
+	store := NewVPodStore()
+	pod := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "ns1",
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "container1"},
+				{Name: "container2"},
+			},
+		},
+	}
+	store.PutPod(pod)
+	store.PutBizStatus("ns1/pod1", model.BizStatusData{Name: "container1", State: string(model.BizStateActivated)})
+	store.PutBizStatus("ns1/pod1", model.BizStatusData{Name: "container2", State: string(model.BizStateResolved)})
+	assert.Len(t, store.GetBizStatuses("ns1/pod1"), 2)
+
+	// status of the removed container is dropped
+	podCopy := pod.DeepCopy()
+	podCopy.Spec.Containers = podCopy.Spec.Containers[:1]
+	store.PutPod(podCopy)
+	bizStatuses := store.GetBizStatuses("ns1/pod1")
+	assert.Len(t, bizStatuses, 1)
+	assert.Equal(t, string(model.BizStateActivated), bizStatuses["container1"].State)
+
+	store.DeletePod("ns1/pod1")
+	assert.Len(t, store.GetBizStatuses("ns1/pod1"), 0)
+}
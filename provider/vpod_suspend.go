@@ -0,0 +1,112 @@
+/**
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package provider
+
+import (
+	"context"
+	"strings"
+
+	"github.com/koupleless/virtual-kubelet/common/utils"
+	"github.com/koupleless/virtual-kubelet/model"
+	"github.com/koupleless/virtual-kubelet/tunnel"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// isSuspendedPod checks whether the pod is suspended by annotation
+func isSuspendedPod(pod *corev1.Pod) bool {
+	return pod.Annotations[model.AnnotationKeyOfSuspended] == "true"
+}
+
+// applySuspend keeps the container of suspended pod out of traffic
+func applySuspend(pod *corev1.Pod, containerStatus *corev1.ContainerStatus) {
+	if isSuspendedPod(pod) {
+		containerStatus.Ready = false
+	}
+}
+
+// suspendPod deactivates the activated biz of the pod just suspended, the biz activated later is deactivated by the biz status callbacks
+func (b *VPodProvider) suspendPod(ctx context.Context, pod *corev1.Pod) {
+	podKey := utils.GetPodKey(pod)
+	bizStatuses := b.vPodStore.GetBizStatuses(podKey)
+	servingBiz := b.servingBizOf(podKey)
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		bizStatus, has := bizStatuses[container.Name]
+		if _, isServing := servingBiz[container.Name]; isServing || !has || !b.bizContainerMatcher.IsBizContainer(pod, container) {
+			continue
+		}
+		if bizStatus.Key != b.bizKeyStrategy.BizUniqueKey(pod, container) {
+			continue
+		}
+		b.suspendBiz(ctx, pod, container, bizStatus)
+	}
+}
+
+// suspendBiz deactivates the activated biz of suspended pod once for each activation if the tunnel implements tunnel.BizDeactivator
+func (b *VPodProvider) suspendBiz(ctx context.Context, pod *corev1.Pod, container *corev1.Container, bizStatus model.BizStatusData) {
+	if model.BizState(strings.ToUpper(bizStatus.State)) != model.BizStateActivated {
+		return
+	}
+
+	containerKey := utils.GetContainerKey(utils.GetPodKey(pod), container.Name)
+	b.bizLock.Lock()
+	handled := b.suspendedBiz[containerKey].Equal(bizStatus.ChangeTime)
+	if !handled {
+		b.suspendedBiz[containerKey] = bizStatus.ChangeTime
+	}
+	b.bizLock.Unlock()
+	if handled {
+		return
+	}
+
+	deactivator, ok := b.tunnel.(tunnel.BizDeactivator)
+	if !ok {
+		log.G(ctx).Warnf("tunnel %s can't deactivate biz, container %s is suspended without deactivated", b.tunnel.Key(), containerKey)
+		return
+	}
+	podKey := utils.GetPodKey(pod)
+	go func(container corev1.Container) {
+		log.G(ctx).Infof("deactivate biz of suspended container %s", containerKey)
+		if err := deactivator.DeactivateBiz(b.nodeName, podKey, &container); err != nil {
+			log.G(ctx).WithError(err).Errorf("failed to deactivate biz of suspended container %s", containerKey)
+		}
+	}(*container)
+}
+
+// resumePod activates the biz of the pod no longer suspended if the tunnel implements tunnel.BizActivator
+func (b *VPodProvider) resumePod(ctx context.Context, pod *corev1.Pod) {
+	podKey := utils.GetPodKey(pod)
+	b.bizLock.Lock()
+	for _, container := range pod.Spec.Containers {
+		delete(b.suspendedBiz, utils.GetContainerKey(podKey, container.Name))
+	}
+	b.bizLock.Unlock()
+
+	activator, ok := b.tunnel.(tunnel.BizActivator)
+	if !ok {
+		log.G(ctx).Warnf("tunnel %s can't activate biz, pod %s is resumed without activated", b.tunnel.Key(), podKey)
+		return
+	}
+	for _, container := range pod.Spec.Containers {
+		if !b.bizContainerMatcher.IsBizContainer(pod, &container) {
+			continue
+		}
+		log.G(ctx).Infof("activate biz of resumed container %s", utils.GetContainerKey(podKey, container.Name))
+		if err := activator.ActivateBiz(b.nodeName, podKey, &container); err != nil {
+			log.G(ctx).WithError(err).Errorf("failed to activate biz of resumed container %s", utils.GetContainerKey(podKey, container.Name))
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/koupleless/virtual-kubelet/common/utils"
+	"github.com/koupleless/virtual-kubelet/model"
+	"github.com/koupleless/virtual-kubelet/tunnel"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSuspendPod(t *testing.T) {
+	tl := &switchingTunnel{MockTunnel: &tunnel.MockTunnel{}}
+	provider, notified := newTestVPodProvider(t, tl, nil)
+	getActivations := func() ([]string, []string) {
+		tl.lock.Lock()
+		defer tl.lock.Unlock()
+		return append([]string{}, tl.activated...), append([]string{}, tl.deactivated...)
+	}
+
+	pod := buildProbedPod(nil, false)
+	pod.Annotations = map[string]string{model.AnnotationKeyOfSuspended: "true"}
+	bizKey := utils.DefaultBizKeyStrategy.BizUniqueKey(pod, &pod.Spec.Containers[0])
+	startProbedPod(provider, pod)
+	suspended := <-notified
+	assert.Equal(t, corev1.PodRunning, suspended.Status.Phase)
+	assert.False(t, suspended.Status.ContainerStatuses[0].Ready)
+	for _, condition := range suspended.Status.Conditions {
+		assert.Equal(t, corev1.ConditionFalse, condition.Status)
+		assert.Equal(t, model.PodReasonSuspended, condition.Reason)
+	}
+	assert.Eventually(t, func() bool {
+		_, deactivated := getActivations()
+		return len(deactivated) == 1 && deactivated[0] == bizKey
+	}, time.Second, 10*time.Millisecond)
+
+	// the same activation is deactivated only once, the status sync never deactivates the biz
+	provider.recordBizStatus(context.TODO(), "default/test-pod", provider.vPodStore.GetBizStatuses("default/test-pod")["biz1"])
+	provider.syncPodStatusToKube(context.TODO(), "default/test-pod")
+	<-notified
+	assert.Never(t, func() bool {
+		_, deactivated := getActivations()
+		return len(deactivated) > 1
+	}, 100*time.Millisecond, 10*time.Millisecond)
+
+	resumed := provider.vPodStore.GetPodByKey("default/test-pod").DeepCopy()
+	resumed.Annotations = map[string]string{}
+	assert.NoError(t, provider.UpdatePod(context.TODO(), resumed))
+	activated, _ := getActivations()
+	assert.Equal(t, []string{bizKey}, activated)
+	assert.Eventually(t, func() bool {
+		for {
+			select {
+			case pod := <-notified:
+				if len(pod.Status.ContainerStatuses) == 1 && pod.Status.ContainerStatuses[0].Ready {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	}, time.Second, 10*time.Millisecond)
+
+	// suspending the running pod deactivates its activated biz
+	suspendedAgain := resumed.DeepCopy()
+	suspendedAgain.Annotations = map[string]string{model.AnnotationKeyOfSuspended: "true"}
+	assert.NoError(t, provider.UpdatePod(context.TODO(), suspendedAgain))
+	assert.Eventually(t, func() bool {
+		_, deactivated := getActivations()
+		return len(deactivated) == 2 && deactivated[1] == bizKey
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,760 @@
+/**
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/koupleless/virtual-kubelet/common/tracker"
+	"github.com/koupleless/virtual-kubelet/common/utils"
+	"github.com/koupleless/virtual-kubelet/model"
+	"github.com/koupleless/virtual-kubelet/tunnel"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podUpdatePhase is the phase of the in-place update of a pod
+type podUpdatePhase string
+
+const (
+	// podUpdatePhaseStoppingOld waits for the biz of the changed containers to be stopped, used by StopFirst updates
+	podUpdatePhaseStoppingOld podUpdatePhase = "StoppingOld"
+	// podUpdatePhaseStartingNew starts the biz of the changed and added containers, StartFirst updates wait for them to be activated
+	podUpdatePhaseStartingNew podUpdatePhase = "StartingNew"
+	// podUpdatePhaseSwitching waits for the new biz of a StartFirst update to be ready and switches the traffic to it
+	podUpdatePhaseSwitching podUpdatePhase = "SwitchingTraffic"
+	// podUpdatePhaseDone means the update is finished, or given up because the old biz is not stopped in time
+	podUpdatePhaseDone podUpdatePhase = "Done"
+	// podUpdatePhaseRolledBack means the new biz of a StartFirst update failed to come up, the old biz keeps serving
+	podUpdatePhaseRolledBack podUpdatePhase = "RolledBack"
+)
+
+// podUpdateTimeout is the deadline of the old biz of an update to be stopped, or the new biz to be ready if the pod has no start timeout
+const podUpdateTimeout = time.Minute
+
+// stoppingBiz is the biz of an old container which must be stopped before the new one starts
+type stoppingBiz struct {
+	containerName string
+	bizKey        string
+	uninstallTime time.Time
+	stopped       bool
+}
+
+// servingBiz is the biz of an old container which keeps serving until the traffic is switched to the new version
+type servingBiz struct {
+	container corev1.Container
+	bizKey    string
+	status    *model.BizStatusData // latest status of the old biz, nil if unknown
+}
+
+// bizUpgrade is the hot upgrade of the biz of a container whose BIZ_VERSION env is the only change
+type bizUpgrade struct {
+	oldContainer corev1.Container
+	newContainer corev1.Container
+}
+
+// podUpdate is the state of the in-place update of a pod, it is advanced by the biz status reported by the tunnel.
+// The state is kept in memory, the update in flight is persisted as a podUpdateRecord in a condition of the pod status,
+// so the update interrupted by a restart is resumed by recoverPodUpdate when the pod is created again.
+type podUpdate struct {
+	generation         int64  // increases with every update of the pod, an update in flight is cancelled by a newer one
+	strategy           string // model.UpdateStrategyStopFirst or model.UpdateStrategyStartFirst
+	phase              podUpdatePhase
+	stoppingBiz        []stoppingBiz
+	servingBiz         []servingBiz
+	startingContainers []string // names of the containers to start, resolved against the latest pod when starting
+}
+
+// keepsServing checks whether the old biz of the update serves instead of the containers in provider
+func (u podUpdate) keepsServing() bool {
+	return u.strategy == model.UpdateStrategyStartFirst &&
+		(u.phase == podUpdatePhaseStartingNew || u.phase == podUpdatePhaseSwitching || u.phase == podUpdatePhaseRolledBack)
+}
+
+// podUpdateRecord is the update in flight persisted as the message of the model.PodConditionTypeBizUpdating condition,
+// the old biz is recorded by its biz key and restored by model.BizKeyStrategy when the update is resumed
+type podUpdateRecord struct {
+	Strategy           string         `json:"strategy"`
+	Phase              podUpdatePhase `json:"phase"`
+	StoppingBiz        []recordedBiz  `json:"stoppingBiz,omitempty"` // old biz of StopFirst update not stopped yet
+	ServingBiz         []recordedBiz  `json:"servingBiz,omitempty"`  // old biz kept serving by StartFirst update
+	StartingContainers []string       `json:"startingContainers"`
+}
+
+// recordedBiz is the old biz of a container recorded in podUpdateRecord
+type recordedBiz struct {
+	ContainerName string `json:"containerName"`
+	BizKey        string `json:"bizKey"`
+}
+
+// podUpdateConditionOf returns the condition recording the update of the pod in flight, nil if there is none
+func (b *VPodProvider) podUpdateConditionOf(podKey string) *corev1.PodCondition {
+	update, has := b.vPodStore.GetPodUpdate(podKey)
+	if !has || update.phase == podUpdatePhaseDone {
+		return nil
+	}
+	record := podUpdateRecord{
+		Strategy:           update.strategy,
+		Phase:              update.phase,
+		StartingContainers: update.startingContainers,
+	}
+	for _, biz := range update.stoppingBiz {
+		if !biz.stopped {
+			record.StoppingBiz = append(record.StoppingBiz, recordedBiz{ContainerName: biz.containerName, BizKey: biz.bizKey})
+		}
+	}
+	for _, biz := range update.servingBiz {
+		record.ServingBiz = append(record.ServingBiz, recordedBiz{ContainerName: biz.container.Name, BizKey: biz.bizKey})
+	}
+	message, err := json.Marshal(record)
+	if err != nil {
+		log.L.WithError(err).Errorf("failed to record update %d of pod %s", update.generation, podKey)
+		return nil
+	}
+	return &corev1.PodCondition{
+		Type:          model.PodConditionTypeBizUpdating,
+		Status:        corev1.ConditionTrue,
+		LastProbeTime: metav1.NewTime(time.Now()),
+		Reason:        string(update.phase),
+		Message:       string(message),
+	}
+}
+
+// interruptedUpdateOf returns the update in flight recorded in the status of the pod, which is lost if the pod is not in provider, nil if there is none
+func interruptedUpdateOf(pod *corev1.Pod) *podUpdateRecord {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type != model.PodConditionTypeBizUpdating || condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		record := &podUpdateRecord{}
+		if err := json.Unmarshal([]byte(condition.Message), record); err != nil {
+			log.L.WithError(err).Warnf("invalid update record of pod %s: %s", utils.GetPodKey(pod), condition.Message)
+			return nil
+		}
+		return record
+	}
+	return nil
+}
+
+// recoverPodUpdate resumes the update of the pod interrupted by a restart with its recorded strategy, only the containers changed by the update
+// are reinstalled: the old biz of a StopFirst update not stopped yet is stopped again before the new containers start, and the new containers
+// of a StartFirst update are started side by side with the old biz again. Returns the containers not changed by the update.
+func (b *VPodProvider) recoverPodUpdate(ctx context.Context, pod *corev1.Pod, record *podUpdateRecord) []corev1.Container {
+	podKey := utils.GetPodKey(pod)
+	restoreContainer := func(biz recordedBiz) (*corev1.Container, bool) {
+		container, err := b.bizKeyStrategy.ParseBizUniqueKey(biz.BizKey)
+		if err != nil {
+			log.G(ctx).WithError(err).Warnf("can't restore old biz %s of container %s", biz.BizKey, utils.GetContainerKey(podKey, biz.ContainerName))
+			return nil, false
+		}
+		container.Name = biz.ContainerName
+		return container, true
+	}
+
+	b.bizLock.Lock()
+	previous, _ := b.vPodStore.GetPodUpdate(podKey)
+	update := podUpdate{
+		generation:         previous.generation + 1,
+		strategy:           record.Strategy,
+		phase:              record.Phase,
+		stoppingBiz:        make([]stoppingBiz, 0, len(record.StoppingBiz)),
+		servingBiz:         make([]servingBiz, 0, len(record.ServingBiz)),
+		startingContainers: record.StartingContainers,
+	}
+	stoppingContainers := make([]corev1.Container, 0, len(record.StoppingBiz))
+	uninstallTime := time.Now()
+	for _, biz := range record.StoppingBiz {
+		if container, ok := restoreContainer(biz); ok {
+			stoppingContainers = append(stoppingContainers, *container)
+			update.stoppingBiz = append(update.stoppingBiz, stoppingBiz{containerName: biz.ContainerName, bizKey: biz.BizKey, uninstallTime: uninstallTime})
+		}
+	}
+	for _, biz := range record.ServingBiz {
+		if container, ok := restoreContainer(biz); ok {
+			update.servingBiz = append(update.servingBiz, servingBiz{container: *container, bizKey: biz.BizKey})
+		}
+	}
+	switch {
+	case update.strategy == model.UpdateStrategyStopFirst:
+		// the new containers are started once the old biz left is stopped
+		update.phase = podUpdatePhaseStoppingOld
+	case update.phase == podUpdatePhaseSwitching:
+		// the new biz is checked again before the traffic is switched
+		update.phase = podUpdatePhaseStartingNew
+	}
+	b.vPodStore.PutPodUpdate(podKey, update)
+	b.bizLock.Unlock()
+
+	log.G(ctx).WithField("podKey", podKey).Warnf("resume %s update interrupted by restart in phase %s for %d containers", update.strategy, record.Phase, len(update.startingContainers))
+	if len(stoppingContainers) > 0 {
+		b.handleBizBatchStop(ctx, pod, stoppingContainers)
+	}
+	b.runPodUpdate(ctx, pod, update)
+
+	changed := make(map[string]bool)
+	for _, name := range update.startingContainers {
+		changed[name] = true
+	}
+	unchanged := make([]corev1.Container, 0, len(pod.Spec.Containers))
+	for _, container := range pod.Spec.Containers {
+		if !changed[container.Name] {
+			unchanged = append(unchanged, container)
+		}
+	}
+	return unchanged
+}
+
+// runPodUpdate runs the recorded update of the pod: StopFirst updates wait for the old biz to be stopped, and StartFirst updates start the new biz at once
+func (b *VPodProvider) runPodUpdate(ctx context.Context, pod *corev1.Pod, update podUpdate) {
+	podKey := utils.GetPodKey(pod)
+	logger := log.G(ctx).WithField("podKey", podKey)
+	switch update.phase {
+	case podUpdatePhaseStoppingOld:
+		// the new containers are started by the biz status callbacks once the old biz is stopped, without blocking the pod workers
+		logger.Infof("update %d waits for %d old biz to be stopped", update.generation, len(update.stoppingBiz))
+		time.AfterFunc(podUpdateTimeout, func() {
+			b.expirePodUpdate(ctx, podKey, update.generation)
+		})
+		b.advancePodUpdate(ctx, podKey, nil)
+	case podUpdatePhaseStartingNew:
+		// the old biz keeps serving until the new biz is ready, the update is rolled back if the new biz doesn't come up in time
+		logger.Infof("update %d starts %d containers side by side with %d old biz", update.generation, len(update.startingContainers), len(update.servingBiz))
+		timeout := b.newBizTimeoutOf(pod)
+		time.AfterFunc(timeout, func() {
+			b.rollbackPodUpdate(ctx, podKey, update.generation, fmt.Sprintf("new biz is not ready in %s", timeout))
+		})
+		b.handleBizBatchStart(ctx, pod, containersOf(pod, update.startingContainers))
+	}
+}
+
+// updateStrategyOf returns the update strategy of the pod, StopFirst if not specified or invalid
+func updateStrategyOf(pod *corev1.Pod) string {
+	if pod.Annotations[model.AnnotationKeyOfUpdateStrategy] == model.UpdateStrategyStartFirst {
+		return model.UpdateStrategyStartFirst
+	}
+	return model.UpdateStrategyStopFirst
+}
+
+// restartGenerationOf returns the restart generation of the pod, empty if never restarted
+func restartGenerationOf(pod *corev1.Pod) string {
+	return pod.Annotations[model.AnnotationKeyOfRestartGeneration]
+}
+
+// isRestartedPod checks whether the restart generation of the pod is bumped by the update
+func isRestartedPod(oldPod, newPod *corev1.Pod) bool {
+	return restartGenerationOf(oldPod) != restartGenerationOf(newPod)
+}
+
+// reinstallsSameBiz checks whether any biz container reinstalled by the update keeps its biz key, the unchanged containers are reinstalled only if restarted.
+// Such biz can't be installed side by side with the old instance on the base.
+func (b *VPodProvider) reinstallsSameBiz(oldPod, newPod *corev1.Pod, restarted bool) bool {
+	for _, oldContainer := range oldPod.Spec.Containers {
+		for _, newContainer := range newPod.Spec.Containers {
+			if oldContainer.Name != newContainer.Name || !b.bizContainerMatcher.IsBizContainer(newPod, &newContainer) {
+				continue
+			}
+			if !restarted && cmp.Equal(oldContainer, newContainer) {
+				continue
+			}
+			if b.bizKeyStrategy.BizUniqueKey(oldPod, &oldContainer) == b.bizKeyStrategy.BizUniqueKey(newPod, &newContainer) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// canSwitchTraffic checks whether the tunnel can activate the new biz and deactivate the old one, which StartFirst updates need
+func (b *VPodProvider) canSwitchTraffic() bool {
+	_, canActivate := b.tunnel.(tunnel.BizActivator)
+	_, canDeactivate := b.tunnel.(tunnel.BizDeactivator)
+	return canActivate && canDeactivate
+}
+
+// isVersionOnlyChange checks whether the BIZ_VERSION env is the only difference between the old and new container
+func isVersionOnlyChange(oldContainer, newContainer corev1.Container) bool {
+	withoutVersion := func(container corev1.Container) (corev1.Container, string) {
+		version := ""
+		env := make([]corev1.EnvVar, 0, len(container.Env))
+		for _, envVar := range container.Env {
+			if envVar.Name == "BIZ_VERSION" {
+				version = envVar.Value
+				continue
+			}
+			env = append(env, envVar)
+		}
+		container.Env = env
+		return container, version
+	}
+	oldWithoutVersion, oldVersion := withoutVersion(oldContainer)
+	newWithoutVersion, newVersion := withoutVersion(newContainer)
+	return oldVersion != newVersion && cmp.Equal(oldWithoutVersion, newWithoutVersion)
+}
+
+// containersOf returns the containers of the pod with the names
+func containersOf(pod *corev1.Pod, names []string) []corev1.Container {
+	ret := make([]corev1.Container, 0, len(names))
+	for _, name := range names {
+		for _, container := range pod.Spec.Containers {
+			if container.Name == name {
+				ret = append(ret, container)
+			}
+		}
+	}
+	return ret
+}
+
+// planPodUpdate records a new update of the pod from the spec in provider to the new pod, and returns it with the containers to stop at once
+// and the biz to hot upgrade if the tunnel supports it. An update in flight is cancelled: the old biz still stopping is waited by the new update,
+// the containers not started yet are started by the new update, and the new biz started side by side is stopped while the old biz keeps serving.
+// All biz containers are reinstalled if the restart generation is bumped, the StartFirst strategy falls back to StopFirst if the tunnel can't switch
+// the traffic between versions, or any reinstalled biz keeps its key.
+func (b *VPodProvider) planPodUpdate(oldPod, newPod *corev1.Pod) (podUpdate, []corev1.Container, []bizUpgrade) {
+	podKey := utils.GetPodKey(newPod)
+	restarted := isRestartedPod(oldPod, newPod)
+	strategy := updateStrategyOf(newPod)
+	if strategy == model.UpdateStrategyStartFirst && (!b.canSwitchTraffic() || b.reinstallsSameBiz(oldPod, newPod, restarted)) {
+		strategy = model.UpdateStrategyStopFirst
+	}
+	b.bizLock.Lock()
+	defer b.bizLock.Unlock()
+
+	previous, _ := b.vPodStore.GetPodUpdate(podKey)
+	update := podUpdate{
+		generation:         previous.generation + 1,
+		strategy:           strategy,
+		phase:              podUpdatePhaseStoppingOld,
+		stoppingBiz:        make([]stoppingBiz, 0),
+		servingBiz:         make([]servingBiz, 0),
+		startingContainers: make([]string, 0),
+	}
+	oldContainerMap := make(map[string]corev1.Container)
+	for _, container := range oldPod.Spec.Containers {
+		oldContainerMap[container.Name] = container
+	}
+	shouldStopContainers := make([]corev1.Container, 0)
+	shouldUpgradeBiz := make([]bizUpgrade, 0)
+	_, canUpgrade := b.tunnel.(tunnel.BizUpgrader)
+
+	// containers of the cancelled update which are not started yet
+	notStarted := make(map[string]bool)
+	if previous.phase == podUpdatePhaseStoppingOld {
+		for _, biz := range previous.stoppingBiz {
+			if !biz.stopped && update.strategy == model.UpdateStrategyStopFirst {
+				update.stoppingBiz = append(update.stoppingBiz, biz)
+			}
+		}
+		for _, name := range previous.startingContainers {
+			notStarted[name] = true
+		}
+	}
+	// the old biz kept serving by the cancelled or rolled back update is the one to replace
+	nameToServingBiz := make(map[string]servingBiz)
+	if previous.keepsServing() {
+		for _, serving := range previous.servingBiz {
+			if container, has := oldContainerMap[serving.container.Name]; has && previous.phase != podUpdatePhaseRolledBack {
+				shouldStopContainers = append(shouldStopContainers, container)
+			}
+			oldContainerMap[serving.container.Name] = serving.container
+			nameToServingBiz[serving.container.Name] = serving
+		}
+	}
+
+	nameToBizStatus := b.vPodStore.GetBizStatuses(podKey)
+	uninstallTime := time.Now()
+	for _, newContainer := range newPod.Spec.Containers {
+		oldContainer, has := oldContainerMap[newContainer.Name]
+		restarting := restarted && b.bizContainerMatcher.IsBizContainer(newPod, &newContainer)
+		if has && cmp.Equal(newContainer, oldContainer) && !notStarted[newContainer.Name] && !restarting {
+			continue
+		}
+		if has && canUpgrade && !notStarted[newContainer.Name] && !restarting && isVersionOnlyChange(oldContainer, newContainer) {
+			// the running biz is hot swapped without stopping
+			shouldUpgradeBiz = append(shouldUpgradeBiz, bizUpgrade{oldContainer: oldContainer, newContainer: newContainer})
+			continue
+		}
+		// changed, added, restarted, or not started by the cancelled update
+		update.startingContainers = append(update.startingContainers, newContainer.Name)
+		if !has || notStarted[newContainer.Name] {
+			// no old biz running
+			continue
+		}
+
+		serving, has := nameToServingBiz[oldContainer.Name]
+		if !has {
+			serving = servingBiz{container: oldContainer, bizKey: b.bizKeyStrategy.BizUniqueKey(oldPod, &oldContainer)}
+			if bizStatus, has := nameToBizStatus[oldContainer.Name]; has && bizStatus.Key == serving.bizKey {
+				serving.status = &bizStatus
+			}
+		}
+		if update.strategy == model.UpdateStrategyStartFirst {
+			update.servingBiz = append(update.servingBiz, serving)
+		} else {
+			shouldStopContainers = append(shouldStopContainers, oldContainer)
+			update.stoppingBiz = append(update.stoppingBiz, stoppingBiz{
+				containerName: oldContainer.Name,
+				bizKey:        serving.bizKey,
+				uninstallTime: uninstallTime,
+			})
+		}
+	}
+	if update.strategy == model.UpdateStrategyStartFirst {
+		update.phase = podUpdatePhaseStartingNew
+	}
+	if len(update.startingContainers) == 0 {
+		update.phase = podUpdatePhaseDone
+	}
+	b.vPodStore.PutPodUpdate(podKey, update)
+	return update, shouldStopContainers, shouldUpgradeBiz
+}
+
+// servingBizOf returns the old biz serving instead of the containers of the pod, keyed by container name
+func (b *VPodProvider) servingBizOf(podKey string) map[string]servingBiz {
+	ret := make(map[string]servingBiz)
+	if update, has := b.vPodStore.GetPodUpdate(podKey); has && update.keepsServing() {
+		for _, serving := range update.servingBiz {
+			ret[serving.container.Name] = serving
+		}
+	}
+	return ret
+}
+
+// reinstallingBizOf returns the status standing for the containers of the pod whose biz is reinstalled with the same biz key by a StopFirst update,
+// keyed by container name, so the stopped old instance isn't taken as the new container terminated
+func (b *VPodProvider) reinstallingBizOf(pod *corev1.Pod) map[string]model.BizStatusData {
+	ret := make(map[string]model.BizStatusData)
+	podKey := utils.GetPodKey(pod)
+	update, has := b.vPodStore.GetPodUpdate(podKey)
+	if !has || update.strategy != model.UpdateStrategyStopFirst || (update.phase != podUpdatePhaseStoppingOld && update.phase != podUpdatePhaseStartingNew) {
+		return ret
+	}
+	for _, biz := range update.stoppingBiz {
+		ret[biz.containerName] = model.BizStatusData{
+			Key:        biz.bizKey,
+			Name:       biz.containerName,
+			PodKey:     podKey,
+			State:      string(model.BizStateResolved),
+			ChangeTime: biz.uninstallTime,
+			Reason:     model.ContainerReasonContainerCreating,
+		}
+	}
+	return ret
+}
+
+// runningContainersOf returns the containers of the pod with the old biz still serving, to stop all biz of the pod
+func (b *VPodProvider) runningContainersOf(pod *corev1.Pod) []corev1.Container {
+	ret := make([]corev1.Container, 0, len(pod.Spec.Containers))
+	ret = append(ret, pod.Spec.Containers...)
+	for _, serving := range b.servingBizOf(utils.GetPodKey(pod)) {
+		ret = append(ret, serving.container)
+	}
+	return ret
+}
+
+// isServingBiz checks whether the biz is the old one kept serving by a StartFirst update, which must not be stopped as orphan
+func (b *VPodProvider) isServingBiz(bizKey string) bool {
+	for _, update := range b.vPodStore.GetPodUpdates() {
+		if !update.keepsServing() {
+			continue
+		}
+		for _, serving := range update.servingBiz {
+			if serving.bizKey == bizKey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AdvancePodUpdates advances the updates with the biz status reported by the tunnel: StopFirst updates start the new containers once the old biz is stopped,
+// and StartFirst updates switch the traffic once the new biz is activated. The biz absent from the reported status is taken as stopped if all biz of the base are reported.
+func (b *VPodProvider) AdvancePodUpdates(ctx context.Context, bizStatusDatas []model.BizStatusData, all bool) {
+	bizKeyToBizStatusData := make(map[string]model.BizStatusData)
+	for _, bizStatusData := range bizStatusDatas {
+		bizKeyToBizStatusData[bizStatusData.Key] = bizStatusData
+	}
+	for podKey, update := range b.vPodStore.GetPodUpdates() {
+		switch {
+		case update.phase == podUpdatePhaseStoppingOld:
+			b.advancePodUpdate(ctx, podKey, func(biz stoppingBiz) bool {
+				bizStatusData, has := bizKeyToBizStatusData[biz.bizKey]
+				if !has {
+					return all
+				}
+				return isBizUninstalled(bizStatusData, biz.uninstallTime)
+			})
+		case update.strategy == model.UpdateStrategyStartFirst && update.phase == podUpdatePhaseStartingNew:
+			b.checkNewBiz(ctx, podKey, update.generation, bizKeyToBizStatusData)
+		}
+	}
+}
+
+// advancePodUpdate marks the old biz of the update stopped by isStopped, and starts the new containers once all old biz is stopped
+func (b *VPodProvider) advancePodUpdate(ctx context.Context, podKey string, isStopped func(biz stoppingBiz) bool) {
+	b.bizLock.Lock()
+	update, has := b.vPodStore.GetPodUpdate(podKey)
+	if !has || update.phase != podUpdatePhaseStoppingOld {
+		b.bizLock.Unlock()
+		return
+	}
+	allStopped := true
+	stopping := make([]stoppingBiz, 0, len(update.stoppingBiz))
+	for _, biz := range update.stoppingBiz {
+		if !biz.stopped && isStopped != nil && isStopped(biz) {
+			biz.stopped = true
+		}
+		allStopped = allStopped && biz.stopped
+		stopping = append(stopping, biz)
+	}
+	update.stoppingBiz = stopping
+	if allStopped {
+		update.phase = podUpdatePhaseStartingNew
+	}
+	b.vPodStore.PutPodUpdate(podKey, update)
+	b.bizLock.Unlock()
+
+	if !allStopped {
+		return
+	}
+
+	pod := b.vPodStore.GetPodByKey(podKey)
+	if pod == nil || pod.DeletionTimestamp != nil {
+		// the pod is deleted during the update
+		return
+	}
+	containers := containersOf(pod, update.startingContainers)
+	for _, container := range containers {
+		for _, biz := range update.stoppingBiz {
+			if biz.containerName == container.Name && biz.bizKey == b.bizKeyStrategy.BizUniqueKey(pod, &container) {
+				// the reinstalled biz is created again, its stopped status is replaced
+				b.vPodStore.ResetBizStatus(podKey, model.BizStatusData{
+					Key:        biz.bizKey,
+					Name:       container.Name,
+					PodKey:     podKey,
+					State:      string(model.BizStateResolved),
+					ChangeTime: time.Now(),
+					Reason:     model.ContainerReasonContainerCreating,
+				})
+			}
+		}
+	}
+	log.G(ctx).WithField("podKey", podKey).Infof("old biz stopped, start %d containers of update %d", len(containers), update.generation)
+	b.handleBizBatchStart(ctx, pod, containers)
+
+	b.bizLock.Lock()
+	defer b.bizLock.Unlock()
+	if current, has := b.vPodStore.GetPodUpdate(podKey); has && current.generation == update.generation {
+		current.phase = podUpdatePhaseDone
+		b.vPodStore.PutPodUpdate(podKey, current)
+	}
+}
+
+// expirePodUpdate gives up the update if its old biz is still not stopped when the deadline passes, the new containers are not started
+func (b *VPodProvider) expirePodUpdate(ctx context.Context, podKey string, generation int64) {
+	b.bizLock.Lock()
+	update, has := b.vPodStore.GetPodUpdate(podKey)
+	if !has || update.generation != generation || update.phase != podUpdatePhaseStoppingOld {
+		b.bizLock.Unlock()
+		return
+	}
+	update.phase = podUpdatePhaseDone
+	b.vPodStore.PutPodUpdate(podKey, update)
+	b.bizLock.Unlock()
+	b.syncPodStatusToKube(ctx, podKey)
+
+	labelMap := make(map[string]string)
+	if pod := b.vPodStore.GetPodByKey(podKey); pod != nil && pod.Labels != nil {
+		labelMap = pod.Labels
+	}
+	message := fmt.Sprintf("old biz of pod %s is not stopped in %s, not start new containers", podKey, podUpdateTimeout)
+	log.G(ctx).Error(message)
+	tracker.G().ErrorReport(labelMap[model.LabelKeyOfTraceID], model.TrackSceneVPodDeploy, model.TrackEventVPodUpdate, message, labelMap, model.CodeContainerStartTimeout)
+}
+
+// newBizTimeoutOf returns the deadline of the new biz of a StartFirst update to be ready
+func (b *VPodProvider) newBizTimeoutOf(pod *corev1.Pod) time.Duration {
+	if timeout := b.startTimeoutOf(pod); timeout > 0 {
+		return timeout
+	}
+	return podUpdateTimeout
+}
+
+// checkNewBiz checks the new biz started side by side by the StartFirst update, the update is rolled back if any new biz fails,
+// or switches the traffic once all new biz is activated
+func (b *VPodProvider) checkNewBiz(ctx context.Context, podKey string, generation int64, bizKeyToBizStatusData map[string]model.BizStatusData) {
+	pod := b.vPodStore.GetPodByKey(podKey)
+	if pod == nil || pod.DeletionTimestamp != nil {
+		return
+	}
+
+	b.bizLock.Lock()
+	update, has := b.vPodStore.GetPodUpdate(podKey)
+	if !has || update.generation != generation || update.phase != podUpdatePhaseStartingNew {
+		b.bizLock.Unlock()
+		return
+	}
+	nameToBizStatus := b.vPodStore.GetBizStatuses(podKey)
+	allActivated := true
+	failure := ""
+	for _, container := range containersOf(pod, update.startingContainers) {
+		bizKey := b.bizKeyStrategy.BizUniqueKey(pod, &container)
+		bizStatus, has := bizKeyToBizStatusData[bizKey]
+		if !has {
+			bizStatus, has = nameToBizStatus[container.Name]
+		}
+		if !has || bizStatus.Key != bizKey {
+			allActivated = false
+			continue
+		}
+		switch state := model.BizState(strings.ToUpper(bizStatus.State)); state {
+		case model.BizStateActivated:
+		case model.BizStateBroken, model.BizStateStopped:
+			failure = fmt.Sprintf("new biz %s is %s: %s", bizKey, strings.ToLower(string(state)), bizStatus.Message)
+		default:
+			allActivated = false
+		}
+	}
+	// the old biz reports without pod, keep its latest status
+	serving := make([]servingBiz, 0, len(update.servingBiz))
+	for _, biz := range update.servingBiz {
+		if bizStatus, has := bizKeyToBizStatusData[biz.bizKey]; has {
+			biz.status = &bizStatus
+		}
+		serving = append(serving, biz)
+	}
+	update.servingBiz = serving
+	if failure == "" && allActivated {
+		update.phase = podUpdatePhaseSwitching
+	}
+	b.vPodStore.PutPodUpdate(podKey, update)
+	b.bizLock.Unlock()
+
+	if failure != "" {
+		b.rollbackPodUpdate(ctx, podKey, generation, failure)
+	} else if allActivated {
+		go b.switchPodUpdate(ctx, podKey, generation)
+	}
+}
+
+// switchPodUpdate waits for the activated new biz of the StartFirst update to pass its readiness probe,
+// then switches the traffic to the new biz and stops the old one
+func (b *VPodProvider) switchPodUpdate(ctx context.Context, podKey string, generation int64) {
+	pod := b.vPodStore.GetPodByKey(podKey)
+	if pod == nil || pod.DeletionTimestamp != nil {
+		return
+	}
+	update, has := b.vPodStore.GetPodUpdate(podKey)
+	if !has || update.generation != generation {
+		return
+	}
+	containers := containersOf(pod, update.startingContainers)
+	for _, container := range containers {
+		if container.ReadinessProbe == nil {
+			continue
+		}
+		period := time.Duration(utils.OrElse(container.ReadinessProbe.PeriodSeconds, 10)) * time.Second
+		err := utils.CheckAndFinallyCall(ctx, func(ctx context.Context) (bool, error) {
+			success, _ := b.prober.runProbe(ctx, pod, &container, container.ReadinessProbe)
+			return success, nil
+		}, b.newBizTimeoutOf(pod), period, func() {}, func() {})
+		if err != nil {
+			b.rollbackPodUpdate(ctx, podKey, generation, fmt.Sprintf("new biz of container %s is not ready in %s", container.Name, b.newBizTimeoutOf(pod)))
+			return
+		}
+	}
+
+	b.bizLock.Lock()
+	update, has = b.vPodStore.GetPodUpdate(podKey)
+	if !has || update.generation != generation || update.phase != podUpdatePhaseSwitching {
+		b.bizLock.Unlock()
+		return
+	}
+	serving := update.servingBiz
+	update.phase = podUpdatePhaseDone
+	update.servingBiz = nil
+	b.vPodStore.PutPodUpdate(podKey, update)
+	b.bizLock.Unlock()
+
+	if activator, ok := b.tunnel.(tunnel.BizActivator); ok {
+		for _, container := range containers {
+			if err := activator.ActivateBiz(b.nodeName, podKey, &container); err != nil {
+				log.G(ctx).WithError(err).Warnf("failed to activate new biz of container %s", container.Name)
+			}
+		}
+	}
+	oldContainers := make([]corev1.Container, 0, len(serving))
+	for _, biz := range serving {
+		oldContainers = append(oldContainers, biz.container)
+	}
+	if deactivator, ok := b.tunnel.(tunnel.BizDeactivator); ok {
+		for _, container := range oldContainers {
+			if err := deactivator.DeactivateBiz(b.nodeName, podKey, &container); err != nil {
+				log.G(ctx).WithError(err).Warnf("failed to deactivate old biz of container %s", container.Name)
+			}
+		}
+	}
+	log.G(ctx).WithField("podKey", podKey).Infof("traffic switched to new biz of update %d, stop %d old biz", generation, len(oldContainers))
+	b.handleBizBatchStop(ctx, pod, oldContainers)
+	b.syncPodStatusToKube(ctx, podKey)
+}
+
+// rollbackPodUpdate stops all new biz started by the StartFirst update which fails to come up, including the added containers without old version,
+// the old biz keeps serving until the next update of the pod
+func (b *VPodProvider) rollbackPodUpdate(ctx context.Context, podKey string, generation int64, message string) {
+	b.bizLock.Lock()
+	update, has := b.vPodStore.GetPodUpdate(podKey)
+	if !has || update.generation != generation || (update.phase != podUpdatePhaseStartingNew && update.phase != podUpdatePhaseSwitching) {
+		b.bizLock.Unlock()
+		return
+	}
+	update.phase = podUpdatePhaseRolledBack
+	b.vPodStore.PutPodUpdate(podKey, update)
+	b.bizLock.Unlock()
+
+	pod := b.vPodStore.GetPodByKey(podKey)
+	if pod == nil {
+		return
+	}
+	newContainers := containersOf(pod, update.startingContainers)
+	oldContainers := make([]corev1.Container, 0, len(update.servingBiz))
+	for _, biz := range update.servingBiz {
+		oldContainers = append(oldContainers, biz.container)
+	}
+	log.G(ctx).WithField("podKey", podKey).Warnf("roll back update %d: %s", generation, message)
+	b.handleBizBatchStop(ctx, pod, newContainers)
+	if activator, ok := b.tunnel.(tunnel.BizActivator); ok {
+		for _, container := range oldContainers {
+			if err := activator.ActivateBiz(b.nodeName, podKey, &container); err != nil {
+				log.G(ctx).WithError(err).Warnf("failed to activate old biz of container %s", container.Name)
+			}
+		}
+	}
+
+	labelMap := pod.Labels
+	if labelMap == nil {
+		labelMap = make(map[string]string)
+	}
+	tracker.G().ErrorReport(labelMap[model.LabelKeyOfTraceID], model.TrackSceneVPodDeploy, model.TrackEventVPodUpdate, message, labelMap, model.CodeContainerStartFailed)
+	if b.eventRecorder != nil {
+		b.eventRecorder.Event(pod, corev1.EventTypeWarning, model.PodEventReasonBizUpgradeRolledBack, message)
+	}
+	b.syncPodStatusToKube(ctx, podKey)
+}
@@ -0,0 +1,511 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/koupleless/virtual-kubelet/common/utils"
+	"github.com/koupleless/virtual-kubelet/model"
+	"github.com/koupleless/virtual-kubelet/tunnel"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func buildUpdatedPod(version string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyAlways,
+			Containers: []corev1.Container{
+				{Name: "biz1", Image: "biz1.jar", Env: []corev1.EnvVar{{Name: "BIZ_VERSION", Value: version}}},
+			},
+		},
+	}
+}
+
+func TestUpdatePod_StateMachine(t *testing.T) {
+	tl := &tunnel.MockTunnel{}
+	provider, _ := newTestVPodProvider(t, tl, nil)
+	lock := sync.Mutex{}
+	reported := make([]model.BizStatusData, 0)
+	tl.RegisterCallback(
+		func(info model.NodeInfo) {},
+		func(s string, data model.NodeStatusData) {},
+		func(s string, data []model.BizStatusData) {},
+		func(s string, data model.BizStatusData) {
+			lock.Lock()
+			defer lock.Unlock()
+			reported = append(reported, data)
+		},
+	)
+	startedKeys := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		ret := make([]string, 0)
+		for _, data := range reported {
+			if data.State == string(model.BizStateUnResolved) {
+				ret = append(ret, data.Key)
+			}
+		}
+		return ret
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, provider.CreatePod(ctx, buildUpdatedPod("0.0.1")))
+	assert.Equal(t, []string{"biz1:0.0.1"}, startedKeys())
+
+	// the update returns at once, the new biz waits for the old one to be stopped
+	assert.NoError(t, provider.UpdatePod(ctx, buildUpdatedPod("0.0.2")))
+	update, has := provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.True(t, has)
+	assert.Equal(t, podUpdatePhaseStoppingOld, update.phase)
+	assert.Equal(t, "biz1:0.0.1", update.stoppingBiz[0].bizKey)
+	assert.Equal(t, []string{"biz1:0.0.1"}, startedKeys())
+
+	// another update cancels the one in flight, the version never started is not stopped
+	assert.NoError(t, provider.UpdatePod(ctx, buildUpdatedPod("0.0.3")))
+	update, _ = provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, int64(2), update.generation)
+	assert.Len(t, update.stoppingBiz, 1)
+	assert.Equal(t, []string{"biz1"}, update.startingContainers)
+
+	// the stopped status of other biz doesn't advance the update
+	provider.AdvancePodUpdates(ctx, []model.BizStatusData{{Key: "biz2:0.0.1", State: string(model.BizStateStopped), ChangeTime: time.Now()}}, false)
+	update, _ = provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseStoppingOld, update.phase)
+
+	provider.AdvancePodUpdates(ctx, []model.BizStatusData{{Key: "biz1:0.0.1", State: string(model.BizStateStopped), ChangeTime: time.Now()}}, false)
+	update, _ = provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseDone, update.phase)
+	assert.Equal(t, []string{"biz1:0.0.1", "biz1:0.0.3"}, startedKeys())
+}
+
+func TestUpdatePod_AllBizReported(t *testing.T) {
+	provider, _ := newTestVPodProvider(t, nil, nil)
+
+	ctx := context.Background()
+	assert.NoError(t, provider.CreatePod(ctx, buildUpdatedPod("0.0.1")))
+	assert.NoError(t, provider.UpdatePod(ctx, buildUpdatedPod("0.0.2")))
+
+	// the old biz is still resolved before uninstalled
+	provider.AdvancePodUpdates(ctx, []model.BizStatusData{{Key: "biz1:0.0.1", State: string(model.BizStateUnResolved), ChangeTime: time.Now().Add(-time.Minute)}}, true)
+	update, _ := provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseStoppingOld, update.phase)
+
+	// the old biz is absent from all biz of the base
+	provider.AdvancePodUpdates(ctx, []model.BizStatusData{}, true)
+	update, _ = provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseDone, update.phase)
+
+	provider.expirePodUpdate(ctx, "default/test-pod", update.generation)
+	assert.NoError(t, provider.DeletePod(ctx, buildUpdatedPod("0.0.2")))
+	_, has := provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.False(t, has)
+}
+
+// callRecordingTunnel records the biz started and stopped by the provider
+type callRecordingTunnel struct {
+	*tunnel.MockTunnel
+	lock  sync.Mutex
+	calls []string
+}
+
+func (c *callRecordingTunnel) StartBiz(nodeName, podKey string, container *corev1.Container) error {
+	c.lock.Lock()
+	c.calls = append(c.calls, "start "+utils.GetBizUniqueKey(container))
+	c.lock.Unlock()
+	return c.MockTunnel.StartBiz(nodeName, podKey, container)
+}
+
+func (c *callRecordingTunnel) StopBiz(nodeName, podKey string, container *corev1.Container) error {
+	c.lock.Lock()
+	c.calls = append(c.calls, "stop "+utils.GetBizUniqueKey(container))
+	c.lock.Unlock()
+	return c.MockTunnel.StopBiz(nodeName, podKey, container)
+}
+
+func (c *callRecordingTunnel) getCalls() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]string{}, c.calls...)
+}
+
+func TestCreatePod_InterruptedUpdate(t *testing.T) {
+	buildPod := func(version string) *corev1.Pod {
+		pod := buildUpdatedPod(version)
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+			Name: "biz2", Image: "biz2.jar", Env: []corev1.EnvVar{{Name: "BIZ_VERSION", Value: "0.0.1"}},
+		})
+		return pod
+	}
+	// interrupt creates the pod updated to the version, and returns the pod status recording the update in flight
+	interrupt := func(provider *VPodProvider, pod func(version string) *corev1.Pod) (corev1.PodStatus, podUpdateRecord) {
+		ctx := context.Background()
+		assert.NoError(t, provider.CreatePod(ctx, pod("0.0.1")))
+		provider.vPodStore.PutBizStatus("default/test-pod", model.BizStatusData{
+			Key:        "biz1:0.0.1",
+			Name:       "biz1",
+			PodKey:     "default/test-pod",
+			State:      string(model.BizStateActivated),
+			ChangeTime: time.Now(),
+		})
+		assert.NoError(t, provider.UpdatePod(ctx, pod("0.0.2")))
+		podStatus, err := provider.GetPodStatus(ctx, provider.vPodStore.GetPodByKey("default/test-pod"))
+		assert.NoError(t, err)
+		condition := podStatus.Conditions[len(podStatus.Conditions)-1]
+		assert.Equal(t, corev1.PodConditionType(model.PodConditionTypeBizUpdating), condition.Type)
+		record := podUpdateRecord{}
+		assert.NoError(t, json.Unmarshal([]byte(condition.Message), &record))
+		assert.Equal(t, string(record.Phase), condition.Reason)
+		assert.Equal(t, []string{"biz1"}, record.StartingContainers)
+		return *podStatus, record
+	}
+
+	// the old biz of the StopFirst update left running is stopped again before the changed container starts
+	ctx := context.Background()
+	provider, _ := newTestVPodProvider(t, nil, nil)
+	podStatus, record := interrupt(provider, buildPod)
+	assert.Equal(t, model.UpdateStrategyStopFirst, record.Strategy)
+	assert.Equal(t, podUpdatePhaseStoppingOld, record.Phase)
+	assert.Equal(t, []recordedBiz{{ContainerName: "biz1", BizKey: "biz1:0.0.1"}}, record.StoppingBiz)
+
+	tl := &callRecordingTunnel{MockTunnel: &tunnel.MockTunnel{}}
+	restarted, _ := newTestVPodProvider(t, tl, nil)
+	pod := buildPod("0.0.2")
+	pod.Status = podStatus
+	assert.NoError(t, restarted.CreatePod(ctx, pod))
+	update, _ := restarted.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseStoppingOld, update.phase)
+	assert.Equal(t, "biz1:0.0.1", update.stoppingBiz[0].bizKey)
+	assert.Equal(t, []string{"stop biz1:0.0.1", "start biz2:0.0.1"}, tl.getCalls())
+
+	restarted.AdvancePodUpdates(ctx, []model.BizStatusData{}, true)
+	update, _ = restarted.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseDone, update.phase)
+	assert.Equal(t, []string{"stop biz1:0.0.1", "start biz2:0.0.1", "start biz1:0.0.2"}, tl.getCalls())
+	resumedStatus, err := restarted.GetPodStatus(ctx, restarted.vPodStore.GetPodByKey("default/test-pod"))
+	assert.NoError(t, err)
+	for _, condition := range resumedStatus.Conditions {
+		assert.NotEqual(t, corev1.PodConditionType(model.PodConditionTypeBizUpdating), condition.Type)
+	}
+
+	// the new biz of the StartFirst update is started side by side again, the old biz keeps serving until the traffic is switched
+	buildStartFirstPod := func(version string) *corev1.Pod {
+		pod := buildPod(version)
+		pod.Annotations = map[string]string{model.AnnotationKeyOfUpdateStrategy: model.UpdateStrategyStartFirst}
+		return pod
+	}
+	provider, _ = newTestVPodProvider(t, &switchingTunnel{MockTunnel: &tunnel.MockTunnel{}}, nil)
+	podStatus, record = interrupt(provider, buildStartFirstPod)
+	assert.Equal(t, model.UpdateStrategyStartFirst, record.Strategy)
+	assert.Equal(t, podUpdatePhaseStartingNew, record.Phase)
+	assert.Equal(t, []recordedBiz{{ContainerName: "biz1", BizKey: "biz1:0.0.1"}}, record.ServingBiz)
+
+	tl = &callRecordingTunnel{MockTunnel: &tunnel.MockTunnel{}}
+	restarted, _ = newTestVPodProvider(t, tl, nil)
+	pod = buildStartFirstPod("0.0.2")
+	pod.Status = podStatus
+	assert.NoError(t, restarted.CreatePod(ctx, pod))
+	update, _ = restarted.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseStartingNew, update.phase)
+	assert.Equal(t, "biz1", update.servingBiz[0].container.Name)
+	assert.True(t, restarted.isServingBiz("biz1:0.0.1"))
+	assert.Equal(t, []string{"start biz1:0.0.2", "start biz2:0.0.1"}, tl.getCalls())
+
+	restarted.AdvancePodUpdates(ctx, []model.BizStatusData{{
+		Key:        "biz1:0.0.2",
+		Name:       "biz1",
+		PodKey:     "default/test-pod",
+		State:      string(model.BizStateActivated),
+		ChangeTime: time.Now(),
+	}}, false)
+	assert.Eventually(t, func() bool {
+		calls := tl.getCalls()
+		return calls[len(calls)-1] == "stop biz1:0.0.1"
+	}, time.Second, 10*time.Millisecond)
+}
+
+type switchingTunnel struct {
+	*tunnel.MockTunnel
+	lock        sync.Mutex
+	activated   []string
+	deactivated []string
+}
+
+func (s *switchingTunnel) ActivateBiz(nodeName, podKey string, container *corev1.Container) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.activated = append(s.activated, utils.GetBizUniqueKey(container))
+	return nil
+}
+
+func (s *switchingTunnel) DeactivateBiz(nodeName, podKey string, container *corev1.Container) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.deactivated = append(s.deactivated, utils.GetBizUniqueKey(container))
+	return nil
+}
+
+func TestUpdatePod_StartFirst(t *testing.T) {
+	tl := &switchingTunnel{MockTunnel: &tunnel.MockTunnel{}}
+	provider, _ := newTestVPodProvider(t, tl, nil)
+	lock := sync.Mutex{}
+	stoppedKeys := make([]string, 0)
+	tl.RegisterCallback(
+		func(info model.NodeInfo) {},
+		func(s string, data model.NodeStatusData) {},
+		func(s string, data []model.BizStatusData) {},
+		func(s string, data model.BizStatusData) {
+			lock.Lock()
+			defer lock.Unlock()
+			if data.State == string(model.BizStateStopped) {
+				stoppedKeys = append(stoppedKeys, data.Key)
+			}
+		},
+	)
+	getStoppedKeys := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, stoppedKeys...)
+	}
+	recorder := record.NewFakeRecorder(10)
+	provider.SetEventRecorder(recorder)
+	buildPod := func(version string) *corev1.Pod {
+		pod := buildUpdatedPod(version)
+		pod.Annotations = map[string]string{model.AnnotationKeyOfUpdateStrategy: model.UpdateStrategyStartFirst}
+		return pod
+	}
+	activate := func(version string) model.BizStatusData {
+		data := model.BizStatusData{
+			Key:        "biz1:" + version,
+			Name:       "biz1",
+			PodKey:     "default/test-pod",
+			State:      string(model.BizStateActivated),
+			ChangeTime: time.Now(),
+		}
+		provider.vPodStore.PutBizStatus("default/test-pod", data)
+		return data
+	}
+	podStatus := func() corev1.ContainerStatus {
+		podStatus, err := provider.GetPodStatus(context.Background(), provider.vPodStore.GetPodByKey("default/test-pod"))
+		assert.NoError(t, err)
+		return podStatus.ContainerStatuses[0]
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, provider.CreatePod(ctx, buildPod("0.0.1")))
+	activate("0.0.1")
+
+	// the new version is started side by side, the old biz keeps serving
+	assert.NoError(t, provider.UpdatePod(ctx, buildPod("0.0.2")))
+	update, _ := provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseStartingNew, update.phase)
+	assert.Equal(t, "biz1:0.0.1", update.servingBiz[0].bizKey)
+	assert.Empty(t, getStoppedKeys())
+	assert.True(t, podStatus().Ready)
+	assert.NoError(t, provider.StopOrphanBiz(ctx, "biz1:0.0.1"))
+	assert.Empty(t, getStoppedKeys())
+
+	// the traffic is switched once the new version is activated
+	provider.AdvancePodUpdates(ctx, []model.BizStatusData{activate("0.0.2")}, false)
+	assert.Eventually(t, func() bool {
+		return len(getStoppedKeys()) == 1
+	}, time.Second, 10*time.Millisecond)
+	update, _ = provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseDone, update.phase)
+	assert.Equal(t, []string{"biz1:0.0.2"}, tl.activated)
+	assert.Equal(t, []string{"biz1:0.0.1"}, tl.deactivated)
+	assert.Equal(t, []string{"biz1:0.0.1"}, getStoppedKeys())
+
+	// the new version fails to come up, the update is rolled back and the added container is stopped as well
+	rollingOut := buildPod("0.0.3")
+	rollingOut.Spec.Containers = append(rollingOut.Spec.Containers, corev1.Container{
+		Name: "biz2", Image: "biz2.jar", Env: []corev1.EnvVar{{Name: "BIZ_VERSION", Value: "0.0.1"}},
+	})
+	assert.NoError(t, provider.UpdatePod(ctx, rollingOut))
+	broken := model.BizStatusData{
+		Key:        "biz1:0.0.3",
+		Name:       "biz1",
+		PodKey:     "default/test-pod",
+		State:      string(model.BizStateBroken),
+		ChangeTime: time.Now(),
+		Message:    "install failed",
+	}
+	provider.vPodStore.PutBizStatus("default/test-pod", broken)
+	provider.AdvancePodUpdates(ctx, []model.BizStatusData{broken}, false)
+	update, _ = provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseRolledBack, update.phase)
+	assert.Equal(t, []string{"biz1:0.0.1", "biz1:0.0.3", "biz2:0.0.1"}, getStoppedKeys())
+	assert.Contains(t, <-recorder.Events, model.PodEventReasonBizUpgradeRolledBack)
+	containerStatus := podStatus()
+	assert.True(t, containerStatus.Ready)
+	assert.NotNil(t, containerStatus.State.Running)
+
+	// deleting the pod stops the old biz still serving
+	assert.NoError(t, provider.DeletePod(ctx, buildPod("0.0.3")))
+	assert.Contains(t, getStoppedKeys(), "biz1:0.0.2")
+}
+
+type upgradingTunnel struct {
+	*tunnel.MockTunnel
+	upgraded []string
+}
+
+func (u *upgradingTunnel) UpgradeBiz(nodeName, podKey string, oldContainer, newContainer *corev1.Container) error {
+	u.upgraded = append(u.upgraded, utils.GetBizUniqueKey(oldContainer)+"->"+utils.GetBizUniqueKey(newContainer))
+	return u.StartBiz(nodeName, podKey, newContainer)
+}
+
+func TestUpdatePod_StartFirstWithoutSwitch(t *testing.T) {
+	provider, _ := newTestVPodProvider(t, nil, nil)
+	buildPod := func(version string) *corev1.Pod {
+		pod := buildUpdatedPod(version)
+		pod.Annotations = map[string]string{model.AnnotationKeyOfUpdateStrategy: model.UpdateStrategyStartFirst}
+		return pod
+	}
+
+	// the tunnel can't switch the traffic between the versions, the old biz is stopped first
+	ctx := context.Background()
+	assert.NoError(t, provider.CreatePod(ctx, buildPod("0.0.1")))
+	assert.NoError(t, provider.UpdatePod(ctx, buildPod("0.0.2")))
+	update, _ := provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, model.UpdateStrategyStopFirst, update.strategy)
+	assert.Empty(t, update.servingBiz)
+	assert.Equal(t, "biz1:0.0.1", update.stoppingBiz[0].bizKey)
+}
+
+func TestUpdatePod_HotUpgrade(t *testing.T) {
+	tl := &upgradingTunnel{MockTunnel: &tunnel.MockTunnel{}}
+	provider, _ := newTestVPodProvider(t, tl, nil)
+	stoppedKeys := make([]string, 0)
+	tl.RegisterCallback(
+		func(info model.NodeInfo) {},
+		func(s string, data model.NodeStatusData) {},
+		func(s string, data []model.BizStatusData) {},
+		func(s string, data model.BizStatusData) {
+			if data.State == string(model.BizStateStopped) {
+				stoppedKeys = append(stoppedKeys, data.Key)
+			}
+		},
+	)
+
+	ctx := context.Background()
+	assert.NoError(t, provider.CreatePod(ctx, buildUpdatedPod("0.0.1")))
+
+	// only BIZ_VERSION changes, the biz is hot upgraded
+	assert.NoError(t, provider.UpdatePod(ctx, buildUpdatedPod("0.0.2")))
+	assert.Equal(t, []string{"biz1:0.0.1->biz1:0.0.2"}, tl.upgraded)
+	assert.Empty(t, stoppedKeys)
+	update, _ := provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseDone, update.phase)
+
+	// other changes reinstall the biz
+	pod := buildUpdatedPod("0.0.3")
+	pod.Spec.Containers[0].Image = "biz1-new.jar"
+	assert.NoError(t, provider.UpdatePod(ctx, pod))
+	assert.Len(t, tl.upgraded, 1)
+	assert.Equal(t, []string{"biz1:0.0.2"}, stoppedKeys)
+	update, _ = provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseStoppingOld, update.phase)
+}
+
+func TestUpdatePod_Restart(t *testing.T) {
+	tl := &tunnel.MockTunnel{}
+	provider, _ := newTestVPodProvider(t, tl, nil)
+	lock := sync.Mutex{}
+	startedKeys := make([]string, 0)
+	tl.RegisterCallback(
+		func(info model.NodeInfo) {},
+		func(s string, data model.NodeStatusData) {},
+		func(s string, data []model.BizStatusData) {},
+		func(s string, data model.BizStatusData) {
+			lock.Lock()
+			defer lock.Unlock()
+			if data.State == string(model.BizStateUnResolved) {
+				startedKeys = append(startedKeys, data.Key)
+			}
+		},
+	)
+	getStartedKeys := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, startedKeys...)
+	}
+
+	ctx := context.Background()
+	pod := buildUpdatedPod("0.0.1")
+	pod.Annotations = map[string]string{model.AnnotationKeyOfUpdateStrategy: model.UpdateStrategyStartFirst}
+	assert.NoError(t, provider.CreatePod(ctx, pod))
+	provider.vPodStore.PutBizStatus("default/test-pod", model.BizStatusData{
+		Key:        "biz1:0.0.1",
+		Name:       "biz1",
+		PodKey:     "default/test-pod",
+		State:      string(model.BizStateActivated),
+		ChangeTime: time.Now(),
+	})
+
+	// the same biz can't run side by side, the restart stops it first
+	restarted := pod.DeepCopy()
+	restarted.Annotations[model.AnnotationKeyOfRestartGeneration] = "1"
+	restarted.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "biz1"}}
+	assert.NoError(t, provider.UpdatePod(ctx, restarted))
+	update, _ := provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, model.UpdateStrategyStopFirst, update.strategy)
+	assert.Equal(t, podUpdatePhaseStoppingOld, update.phase)
+	assert.Equal(t, "biz1:0.0.1", update.stoppingBiz[0].bizKey)
+	assert.Equal(t, []string{"biz1"}, update.startingContainers)
+
+	// the stopped old instance isn't taken as the container terminated
+	provider.vPodStore.PutBizStatus("default/test-pod", model.BizStatusData{
+		Key:        "biz1:0.0.1",
+		Name:       "biz1",
+		PodKey:     "default/test-pod",
+		State:      string(model.BizStateStopped),
+		ChangeTime: time.Now(),
+	})
+	podStatus, err := provider.GetPodStatus(ctx, provider.vPodStore.GetPodByKey("default/test-pod"))
+	assert.NoError(t, err)
+	assert.Equal(t, corev1.PodRunning, podStatus.Phase)
+	assert.Equal(t, model.ContainerReasonContainerCreating, podStatus.ContainerStatuses[0].State.Waiting.Reason)
+	assert.Equal(t, int32(0), podStatus.ContainerStatuses[0].RestartCount)
+
+	provider.AdvancePodUpdates(ctx, []model.BizStatusData{{Key: "biz1:0.0.1", State: string(model.BizStateStopped), ChangeTime: time.Now()}}, false)
+	update, _ = provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseDone, update.phase)
+	assert.Equal(t, []string{"biz1:0.0.1", "biz1:0.0.1"}, getStartedKeys())
+	assert.Equal(t, string(model.BizStateResolved), provider.vPodStore.GetBizStatuses("default/test-pod")["biz1"].State)
+
+	// the restart is counted once the new instance is activated
+	provider.recordBizStatus(ctx, "default/test-pod", model.BizStatusData{
+		Key:        "biz1:0.0.1",
+		Name:       "biz1",
+		PodKey:     "default/test-pod",
+		State:      string(model.BizStateActivated),
+		ChangeTime: time.Now(),
+	})
+	podStatus, err = provider.GetPodStatus(ctx, provider.vPodStore.GetPodByKey("default/test-pod"))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), podStatus.ContainerStatuses[0].RestartCount)
+
+	// the same restart generation reinstalls nothing
+	assert.NoError(t, provider.UpdatePod(ctx, restarted))
+	update, _ = provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, podUpdatePhaseDone, update.phase)
+	assert.Len(t, getStartedKeys(), 2)
+
+	// the changed container keeping its biz key is stopped first without restart
+	changed := restarted.DeepCopy()
+	changed.Spec.Containers[0].Image = "biz1-patched.jar"
+	assert.NoError(t, provider.UpdatePod(ctx, changed))
+	update, _ = provider.vPodStore.GetPodUpdate("default/test-pod")
+	assert.Equal(t, model.UpdateStrategyStopFirst, update.strategy)
+	assert.Equal(t, podUpdatePhaseStoppingOld, update.phase)
+	assert.Equal(t, "biz1:0.0.1", update.stoppingBiz[0].bizKey)
+	assert.Empty(t, update.servingBiz)
+	assert.Len(t, getStartedKeys(), 2)
+}
@@ -84,6 +84,8 @@ var _ = BeforeSuite(func() {
 		Env:        env,
 		VPodType:   vPodIdentity,
 		IsCluster:  true,
+
+		VNodeDeactivationGracePeriod: time.Second,
 	}, &tl)
 
 	err = vnodeController.SetupWithManager(ctx, k8sManager)
@@ -79,7 +79,7 @@ var _ = Describe("VPod Lifecycle Test", func() {
 		It(tasks[2], func() {
 			for _, container := range basicPod.Spec.Containers {
 				podKey := utils.GetPodKey(&basicPod)
-				key := tl.GetBizUniqueKey(&container)
+				key := utils.GetBizUniqueKey(&container)
 				time.Sleep(time.Second)
 				tl.UpdateBizStatus(nodeName, key, model.BizStatusData{
 					Key:        key,
@@ -102,7 +102,7 @@ var _ = Describe("VPod Lifecycle Test", func() {
 		It(tasks[3], func() {
 			container := basicPod.Spec.Containers[0]
 			podKey := utils.GetPodKey(&basicPod)
-			key := tl.GetBizUniqueKey(&container)
+			key := utils.GetBizUniqueKey(&container)
 			tl.UpdateBizStatus(nodeName, key, model.BizStatusData{
 				Key:        key,
 				Name:       container.Name,
@@ -123,7 +123,7 @@ var _ = Describe("VPod Lifecycle Test", func() {
 		It(tasks[4], func() {
 			container := basicPod.Spec.Containers[0]
 			podKey := utils.GetPodKey(&basicPod) + "-wrong"
-			key := tl.GetBizUniqueKey(&container)
+			key := utils.GetBizUniqueKey(&container)
 			tl.UpdateBizStatus(nodeName, key, model.BizStatusData{
 				Key:        key,
 				Name:       container.Name,
@@ -145,7 +145,7 @@ var _ = Describe("VPod Lifecycle Test", func() {
 		It(tasks[5], func() {
 			container := basicPod.Spec.Containers[0]
 			podKey := utils.GetPodKey(&basicPod)
-			key := tl.GetBizUniqueKey(&container)
+			key := utils.GetBizUniqueKey(&container)
 			tl.UpdateBizStatus(nodeName, key, model.BizStatusData{
 				Key:        key,
 				Name:       container.Name,
@@ -215,7 +215,7 @@ var _ = Describe("VPod Lifecycle Test", func() {
 		It(tasks[2], func() {
 			for _, container := range basicPod2.Spec.Containers {
 				podKey := utils.GetPodKey(&basicPod2)
-				key := tl.GetBizUniqueKey(&container)
+				key := utils.GetBizUniqueKey(&container)
 				time.Sleep(time.Second)
 				tl.UpdateBizStatus(nodeName, key, model.BizStatusData{
 					Key:        key,
@@ -23,9 +23,10 @@ type MockTunnel struct {
 	OnSingleBizStatusArrived
 	OnAllBizStatusArrived
 
-	bizStatusStorage map[string]map[string]model.BizStatusData
-	nodeStorage      map[string]Node
-	NodeNotReady     map[string]bool
+	bizStatusStorage  map[string]map[string]model.BizStatusData
+	nodeStorage       map[string]Node
+	NodeNotReady      map[string]bool
+	NodeUnschedulable map[string]bool
 }
 
 func (m *MockTunnel) OnNodeNotReady(nodeName string) {
@@ -35,6 +36,15 @@ func (m *MockTunnel) OnNodeNotReady(nodeName string) {
 	return
 }
 
+func (m *MockTunnel) OnNodeUnschedulableChanged(nodeName string, unschedulable bool) {
+	m.Lock()
+	defer m.Unlock()
+	if m.NodeUnschedulable == nil {
+		m.NodeUnschedulable = map[string]bool{}
+	}
+	m.NodeUnschedulable[nodeName] = unschedulable
+}
+
 func (m *MockTunnel) PutNode(ctx context.Context, nodeName string, node Node) {
 	m.Lock()
 	defer m.Unlock()
@@ -72,6 +82,7 @@ func (m *MockTunnel) Start(clientID string, env string) error {
 	m.bizStatusStorage = map[string]map[string]model.BizStatusData{}
 	m.nodeStorage = map[string]Node{}
 	m.NodeNotReady = map[string]bool{}
+	m.NodeUnschedulable = map[string]bool{}
 	return nil
 }
 
@@ -155,10 +166,6 @@ func (m *MockTunnel) StopBiz(nodeName, podKey string, container *corev1.Containe
 	return nil
 }
 
-func (m *MockTunnel) GetBizUniqueKey(container *corev1.Container) string {
-	return utils.GetBizUniqueKey(container)
-}
-
 func convertContainerMap2ContainerList(containerMap map[string]model.BizStatusData) []model.BizStatusData {
 	ret := make([]model.BizStatusData, 0)
 	for _, container := range containerMap {
@@ -1,6 +1,8 @@
 package tunnel
 
 import (
+	"context"
+
 	"github.com/koupleless/virtual-kubelet/model"
 	v1 "k8s.io/api/core/v1"
 )
@@ -17,6 +19,9 @@ type OnAllBizStatusArrived func(string, []model.BizStatusData)
 // OnSingleBizStatusArrived is one container status data callback, will update container-vpod status to k8s
 type OnSingleBizStatusArrived func(string, model.BizStatusData)
 
+// Tunnel is the communication between vnodes and bases.
+// The unique key of biz in model.BizStatusData is defined by model.BizKeyStrategy, a tunnel owns the key by implementing it,
+// otherwise the key strategy of the controller config is used. A tunnel still implementing GetBizUniqueKey is adapted by utils.LegacyBizKeyStrategy.
 type Tunnel interface {
 	// Key is the identity of Tunnel, will set to node label for special usage
 	Key() string
@@ -50,7 +55,39 @@ type Tunnel interface {
 
 	// StopBiz is the func calls for vnode to shut down a container , you need to start to shut down container and call OnShutdownContainerResponseArrived when shut down process complete with a response
 	StopBiz(nodeName, podKey string, container *v1.Container) error
+}
+
+// NodeUnschedulableObserver is an optional capability of Tunnel to be told when a vnode is cordoned or uncordoned,
+// the base should stop accepting new biz installations when unschedulable
+type NodeUnschedulableObserver interface {
+	// OnNodeUnschedulableChanged is the func call when a vnode is cordoned or uncordoned, and when a cordoned vnode is taken over
+	OnNodeUnschedulableChanged(nodeName string, unschedulable bool)
+}
+
+// BizCommandExecutor is an optional capability of Tunnel to run commands against a biz instance in the base, exec probes of vpods
+// are executed through it, vpods with exec probes never pass them if the tunnel doesn't implement it
+type BizCommandExecutor interface {
+	// ExecBizCommand runs the command for the biz of the container and returns the output, a nil error means the command succeeded
+	ExecBizCommand(ctx context.Context, nodeName, podKey string, container *v1.Container, command []string) (string, error)
+}
+
+// BizActivator is an optional capability of Tunnel to switch the traffic to a biz installed side by side with its old version,
+// the new version of a StartFirst update is activated once it is ready, StartFirst updates need both BizActivator and BizDeactivator
+type BizActivator interface {
+	// ActivateBiz makes the biz of the container serve traffic
+	ActivateBiz(nodeName, podKey string, container *v1.Container) error
+}
+
+// BizUpgrader is an optional capability of Tunnel to hot swap the biz to a new version in one operation,
+// used by pod updates which only change the BIZ_VERSION env of a container
+type BizUpgrader interface {
+	// UpgradeBiz replaces the biz of the old container with the biz of the new container, the status of the new biz is reported as usual
+	UpgradeBiz(nodeName, podKey string, oldContainer, newContainer *v1.Container) error
+}
 
-	// GetBizUniqueKey is the func returns a unique key of a container in a pod, vnode will use this unique key to find target Container status
-	GetBizUniqueKey(container *v1.Container) string
+// BizDeactivator is an optional capability of Tunnel to switch the traffic off a biz before it is uninstalled,
+// the biz of vpods being deleted is deactivated after the preStop hooks
+type BizDeactivator interface {
+	// DeactivateBiz stops the biz of the container from serving traffic, the biz is uninstalled by StopBiz later
+	DeactivateBiz(nodeName, podKey string, container *v1.Container) error
 }
@@ -233,8 +233,15 @@ func NewNode(name string, newProvider NewProviderFunc, opts ...NodeOpt) (*Node,
 		return nil, errors.New("no client provided")
 	}
 
+	var eb record.EventBroadcaster
+	if cfg.EventRecorder == nil {
+		eb = record.NewBroadcaster()
+		cfg.EventRecorder = eb.NewRecorder(scheme.Scheme, v1.EventSource{Component: path.Join(name, "pod-controller")})
+	}
+
 	podProvider, nodeProvider, err := newProvider(ProviderConfig{
-		Node: &cfg.Node,
+		Node:          &cfg.Node,
+		EventRecorder: cfg.EventRecorder,
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating provider")
@@ -252,12 +259,6 @@ func NewNode(name string, newProvider NewProviderFunc, opts ...NodeOpt) (*Node,
 		return nil, errors.Wrap(err, "error creating node controller")
 	}
 
-	var eb record.EventBroadcaster
-	if cfg.EventRecorder == nil {
-		eb = record.NewBroadcaster()
-		cfg.EventRecorder = eb.NewRecorder(scheme.Scheme, v1.EventSource{Component: path.Join(name, "pod-controller")})
-	}
-
 	podController, err := node.NewPodController(node.PodControllerConfig{
 		NodeName:      name,
 		EventRecorder: cfg.EventRecorder,
@@ -3,6 +3,7 @@ package nodeutil
 import (
 	"github.com/koupleless/virtual-kubelet/virtual_kubelet/node"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 )
 
 // Provider contains the methods required to implement a virtual-kubelet provider.
@@ -20,6 +21,9 @@ type ProviderConfig struct {
 	// Since the provider is bootstrapped after the node object is configured
 	// Primarily this is due to carry-over from the pre-1.0 interfaces that expect the provider instead of the direct *caller* to configure the node.
 	Node *v1.Node
+
+	// EventRecorder records the events of pods, same as the one used by the pod controller
+	EventRecorder record.EventRecorder
 }
 
 // NewProviderFunc is used from NewNodeFromClient to bootstrap a provider using the client/listers/etc created there.
@@ -18,7 +18,6 @@ import (
 	"context"
 	"fmt"
 	"github.com/koupleless/virtual-kubelet/common/utils"
-	"github.com/koupleless/virtual-kubelet/model"
 	"github.com/koupleless/virtual-kubelet/virtual_kubelet/internal/queue"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
@@ -66,7 +65,7 @@ type PodLifecycleHandler interface {
 	// The PodStatus returned is expected to be immutable, and may be accessed
 	// concurrently outside of the calling goroutine. Therefore it is recommended
 	// to return a version after DeepCopy.
-	GetPodStatus(ctx context.Context, pod *corev1.Pod, bizStatus model.BizStatusData) (*corev1.PodStatus, error)
+	GetPodStatus(ctx context.Context, pod *corev1.Pod) (*corev1.PodStatus, error)
 
 	// GetPods retrieves a list of all pods running on the provider (can be cached).
 	// The Pods returned are expected to be immutable, and may be accessed
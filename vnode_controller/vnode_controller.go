@@ -55,6 +55,8 @@ type VNodeController struct {
 
 	cache cache.Cache // The cache for the controller
 
+	apiReader client.Reader // The uncached reader for the objects not watched by the cache
+
 	ready chan struct{} // The channel for the controller to be ready
 
 	tunnel tunnel.Tunnel
@@ -62,6 +64,24 @@ type VNodeController struct {
 	vNodeStore *provider.VNodeStore // The runtime info store for the controller
 
 	pseudoNodeIP string // The pseudo node IP for the controller, will be used as the node IP for vnodes.
+
+	deactivationGracePeriod time.Duration // The grace period to drain a deactivated vnode before removing it
+
+	evictVPodsOnDeactivation bool // Whether to evict the vpods of a deactivated vnode
+
+	keepDeadVNode bool // Whether to keep the Node of a dead vnode as NotReady instead of deleting it at once
+
+	deadVNodeRetention time.Duration // The time to keep the Node of a dead vnode before deleting it when keepDeadVNode is set
+
+	stopBizOnVNodeDeleted bool // Whether to stop the biz modules when the Node of a vnode is deleted by user
+
+	bizContainerMatcher model.BizContainerMatcher // Decides which containers of vpods are biz modules
+
+	bizKeyStrategy model.BizKeyStrategy // Unique key of biz modules
+
+	statusMapper model.StatusMapper // Maps biz status to container status
+
+	bizStartTimeout time.Duration // Default deadline of a started biz to be activated
 }
 
 // Reconcile is the main reconcile function for the controller
@@ -88,6 +108,37 @@ func NewVNodeController(config *model.BuildVNodeControllerConfig, tunnel tunnel.
 		config.VNodeWorkerNum = 1
 	}
 
+	if config.VNodeDeactivationGracePeriod == 0 {
+		config.VNodeDeactivationGracePeriod = model.NodeDeactivatedGracePeriodSeconds * time.Second
+	}
+
+	if config.DeadVNodeRetention == 0 {
+		config.DeadVNodeRetention = model.DeadVNodeRetentionSeconds * time.Second
+	}
+
+	if config.BizContainerMatcher == nil {
+		config.BizContainerMatcher = utils.DefaultBizContainerMatcher
+	}
+
+	if config.BizKeyStrategy == nil {
+		if tunnelBizKeyStrategy, ok := tunnel.(model.BizKeyStrategy); ok {
+			config.BizKeyStrategy = tunnelBizKeyStrategy
+		} else if tunnelBizKeyGetter, ok := tunnel.(utils.BizUniqueKeyGetter); ok {
+			log.L.Warnf("tunnel %s only implements GetBizUniqueKey, implement model.BizKeyStrategy to parse its biz keys", tunnel.Key())
+			config.BizKeyStrategy = utils.LegacyBizKeyStrategy{Getter: tunnelBizKeyGetter}
+		} else {
+			config.BizKeyStrategy = utils.DefaultBizKeyStrategy
+		}
+	}
+
+	if config.StatusMapper == nil {
+		if tunnelStatusMapper, ok := tunnel.(model.StatusMapper); ok {
+			config.StatusMapper = tunnelStatusMapper
+		} else {
+			config.StatusMapper = utils.NewDefaultStatusMapper()
+		}
+	}
+
 	return &VNodeController{
 		clientID:         config.ClientID,
 		env:              config.Env,
@@ -101,6 +152,16 @@ func NewVNodeController(config *model.BuildVNodeControllerConfig, tunnel tunnel.
 		pseudoNodeIP:     config.PseudoNodeIP,
 		ready:            make(chan struct{}),
 		tunnel:           tunnel,
+
+		deactivationGracePeriod:  config.VNodeDeactivationGracePeriod,
+		evictVPodsOnDeactivation: config.EvictVPodsOnDeactivation,
+		keepDeadVNode:            config.KeepDeadVNode,
+		deadVNodeRetention:       config.DeadVNodeRetention,
+		stopBizOnVNodeDeleted:    config.StopBizOnVNodeDeleted,
+		bizContainerMatcher:      config.BizContainerMatcher,
+		bizKeyStrategy:           config.BizKeyStrategy,
+		statusMapper:             config.StatusMapper,
+		bizStartTimeout:          config.BizStartTimeout,
 	}, nil
 }
 
@@ -111,6 +172,7 @@ func (vNodeController *VNodeController) SetupWithManager(ctx context.Context, mg
 
 	vNodeController.client = mgr.GetClient()
 	vNodeController.cache = mgr.GetCache()
+	vNodeController.apiReader = mgr.GetAPIReader()
 
 	log.G(ctx).Info("Setting up register controller")
 
@@ -153,6 +215,31 @@ func (vNodeController *VNodeController) SetupWithManager(ctx context.Context, mg
 		return err
 	}
 
+	vnodeComponentRequirement, _ := labels.NewRequirement(model.LabelKeyOfComponent, selection.In, []string{model.ComponentVNode})
+	vnodeEnvRequirement, _ := labels.NewRequirement(model.LabelKeyOfEnv, selection.In, []string{vNodeController.env})
+
+	nodeHandler := handler.TypedFuncs[*corev1.Node, reconcile.Request]{
+		CreateFunc: func(ctx context.Context, e event.TypedCreateEvent[*corev1.Node], w workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			<-vNodeController.ready
+			vNodeController.nodeAddHandler(ctx, e.Object)
+		},
+		UpdateFunc: func(ctx context.Context, e event.TypedUpdateEvent[*corev1.Node], w workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			<-vNodeController.ready
+			vNodeController.nodeUpdateHandler(ctx, e.ObjectOld, e.ObjectNew)
+		},
+		DeleteFunc: func(ctx context.Context, e event.TypedDeleteEvent[*corev1.Node], w workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			<-vNodeController.ready
+			vNodeController.nodeDeleteHandler(ctx, e.Object)
+		},
+	}
+
+	if err = c.Watch(source.Kind(mgr.GetCache(), &corev1.Node{}, &nodeHandler, &predicates.VNodePredicate{
+		VNodeLabelSelector: labels.NewSelector().Add(*vnodeComponentRequirement, *vnodeEnvRequirement),
+	})); err != nil {
+		log.G(ctx).WithError(err).Error("unable to watch Nodes")
+		return err
+	}
+
 	go func() {
 		// wait for all tunnel to be ready
 		utils.CheckAndFinallyCall(context.Background(), func(ctx context.Context) (bool, error) {
@@ -232,14 +319,51 @@ func (vNodeController *VNodeController) discoverPreviousPods(ctx context.Context
 // They are used to manage the state of the virtual nodes and synchronize the node and pod information.
 
 // onBaseDiscovered is an event handler for when a new node is discovered.
-// It starts a virtual node if the node's status is activated, otherwise it shuts down the virtual node.
+// It starts a virtual node if the node's status is activated, otherwise it drains the virtual node and shuts it down after the grace period.
 func (vNodeController *VNodeController) onBaseDiscovered(data model.NodeInfo) {
+	if vNodeController.vNodeStore.CheckDecommissionedOnProviderArrived(data.Metadata.Name, data.State) {
+		log.L.Debugf("skip discovered base of decommissioned vnode %s", data.Metadata.Name)
+		return
+	}
 	if data.State == model.NodeStateActivated {
 		vNodeController.startVNode(data)
-	} else {
-		// TODO: update node status
 	}
-	vNodeController.vNodeStore.UpdateNodeStateOnProviderArrived(data.Metadata.Name, data.State)
+	vNodeController.updateNodeState(data.Metadata.Name, data.State)
+}
+
+// updateNodeState updates the liveness of the vnode, and drains or restores the vnode when the base turns deactivated or activated.
+func (vNodeController *VNodeController) updateNodeState(nodeName string, state model.NodeState) {
+	if !vNodeController.vNodeStore.UpdateNodeStateOnProviderArrived(nodeName, state) {
+		return
+	}
+
+	vNode := vNodeController.vNodeStore.GetVNode(nodeName)
+	if vNode == nil || !vNode.IsLeader(vNodeController.clientID) {
+		return
+	}
+
+	ctx := context.WithValue(context.Background(), "nodeName", nodeName)
+	if state == model.NodeStateActivated {
+		log.G(ctx).Infof("base of vnode %s is activated again, restore the vnode", nodeName)
+		if err := vNode.UnDrain(ctx); err != nil {
+			log.G(ctx).WithError(err).Errorf("failed to restore vnode %s", nodeName)
+		}
+		return
+	}
+
+	log.G(ctx).Infof("base of vnode %s is deactivated, drain the vnode and shutdown it after %s", nodeName, vNodeController.deactivationGracePeriod)
+	if err := vNode.Drain(ctx, model.NodeReasonBaseDeactivated, "base is deactivated", vNodeController.evictVPodsOnDeactivation); err != nil {
+		log.G(ctx).WithError(err).Errorf("failed to drain vnode %s", nodeName)
+	}
+
+	time.AfterFunc(vNodeController.deactivationGracePeriod, func() {
+		// the base may be activated again, or the vnode may be recreated during the grace period
+		if vNodeController.vNodeStore.GetVNode(nodeName) != vNode || !vNode.Liveness.IsDeactivated() {
+			return
+		}
+		log.G(ctx).Infof("shutdown deactivated vnode %s after grace period", nodeName)
+		vNodeController.shutdownVNode(nodeName)
+	})
 }
 
 // onBaseStatusArrived is an event handler for when status data is received for a node.
@@ -249,13 +373,15 @@ func (vNodeController *VNodeController) onBaseStatusArrived(nodeName string, dat
 
 	// if not exist then return
 	if vNode == nil {
+		// refresh the last seen time of decommissioned vnode
+		vNodeController.vNodeStore.CheckDecommissionedOnProviderArrived(nodeName, utils.OrElse(data.NodeState, model.NodeStateActivated))
 		return
 	}
 
 	if vNode.IsLeader(vNodeController.clientID) {
 		vNode.SyncNodeStatus(data)
 		if data.NodeState != "" {
-			vNodeController.vNodeStore.UpdateNodeStateOnProviderArrived(nodeName, data.NodeState)
+			vNodeController.updateNodeState(nodeName, data.NodeState)
 		}
 	}
 }
@@ -273,7 +399,7 @@ func (vNodeController *VNodeController) onAllBizStatusArrived(nodeName string, b
 	if vNode.IsLeader(vNodeController.clientID) {
 		ctx := context.Background()
 		pods, _ := vNodeController.listPodFromKube(ctx, nodeName)
-		bizStatusDatasWithPodKey, bizStatusDatasWithNoPodKey := utils.FillPodKey(pods, bizStatusDatas)
+		bizStatusDatasWithPodKey, bizStatusDatasWithNoPodKey := utils.FillPodKey(pods, bizStatusDatas, vNodeController.bizContainerMatcher, vNodeController.bizKeyStrategy)
 
 		vNode.SyncBatchBizStatusToKube(ctx, bizStatusDatasWithPodKey, bizStatusDatasWithNoPodKey)
 	}
@@ -292,7 +418,7 @@ func (vNodeController *VNodeController) onSingleBizStatusArrived(nodeName string
 	if vNode.IsLeader(vNodeController.clientID) {
 		ctx := context.Background()
 		pods, _ := vNodeController.listPodFromKube(ctx, nodeName)
-		bizStatusDatasWithPodKey, bizStatusDatasWithNoPodKey := utils.FillPodKey(pods, []model.BizStatusData{bizStatusData})
+		bizStatusDatasWithPodKey, bizStatusDatasWithNoPodKey := utils.FillPodKey(pods, []model.BizStatusData{bizStatusData}, vNodeController.bizContainerMatcher, vNodeController.bizKeyStrategy)
 		vNode.SyncOneNodeBizStatusToKube(context.TODO(), bizStatusDatasWithPodKey, bizStatusDatasWithNoPodKey)
 	}
 }
@@ -345,6 +471,10 @@ func (vNodeController *VNodeController) isValidStatus(ctx context.Context, vNode
 	}
 
 	if vNode.Liveness.IsDead() {
+		if !vNodeController.shouldShutdownDeadVNode(vNode) {
+			log.G(ctx).Warnf("vnode %s is dead", vNode.GetNodeName())
+			return false
+		}
 		log.G(ctx).Warnf("check and shutdown dead vnode: %s", vNode.GetNodeName())
 		vNodeController.shutdownVNode(vNode.GetNodeName())
 		return false
@@ -358,6 +488,27 @@ func (vNodeController *VNodeController) isValidStatus(ctx context.Context, vNode
 	return true
 }
 
+// shouldShutdownDeadVNode checks whether the dead vnode should be shutdown and its Node deleted:
+// a deactivated vnode is shutdown after the drain grace period, and a kept dead vnode after the retention.
+func (vNodeController *VNodeController) shouldShutdownDeadVNode(vNode *provider.VNode) bool {
+	if vNode.Liveness.IsDeactivated() {
+		return false
+	}
+	if !vNodeController.keepDeadVNode {
+		return true
+	}
+	return time.Since(vNode.Liveness.LatestHeartBeatTime) >= model.NodeToDeadMaxSeconds*time.Second+vNodeController.deadVNodeRetention
+}
+
+// canDeletePods checks whether the pods of the vnode can be deleted, the pods of a draining or kept dead vnode are deleted
+// without the base, so they can be finalized.
+func (vNodeController *VNodeController) canDeletePods(ctx context.Context, vNode *provider.VNode) bool {
+	if vNode.IsReady() && vNode.Liveness.IsDead() && !vNodeController.shouldShutdownDeadVNode(vNode) {
+		return true
+	}
+	return vNodeController.isValidStatus(ctx, vNode)
+}
+
 // This function handles pod updates by checking if the pod is new or if its status has changed.
 func (vNodeController *VNodeController) podUpdateHandler(ctx context.Context, oldPodFromKubernetes, newPodFromKubernetes *corev1.Pod) {
 	ctx, cancel := context.WithCancel(ctx)
@@ -385,7 +536,12 @@ func (vNodeController *VNodeController) podUpdateHandler(ctx context.Context, ol
 	diff := cmp.Diff(oldPodFromKubernetes, newPodFromKubernetes)
 	log.G(ctx).Infof("try to update pod %s/%s with diff %s in podUpdateHandler.", vNode.GetNodeName(), podKey, diff)
 
-	if !vNodeController.isValidStatus(ctx, vNode) {
+	if newPodFromKubernetes.DeletionTimestamp != nil {
+		if !vNodeController.canDeletePods(ctx, vNode) {
+			log.G(ctx).Warnf("can not delete pod %s because vnode %s is invalid: ", podKey, vNode.GetNodeName())
+			return
+		}
+	} else if !vNodeController.isValidStatus(ctx, vNode) {
 		log.G(ctx).Warnf("can not update pod %s because vnode %s is invalid: ", podKey, vNode.GetNodeName())
 		return
 	}
@@ -422,7 +578,7 @@ func (vNodeController *VNodeController) podDeleteHandler(ctx context.Context, po
 	}
 
 	podKey := utils.GetPodKey(podFromKubernetes)
-	if !vNodeController.isValidStatus(ctx, vNode) {
+	if !vNodeController.canDeletePods(ctx, vNode) {
 		log.G(ctx).Warnf("can not delete pod %s because vnode %s is invalid: ", podKey, vNode.GetNodeName())
 		return
 	}
@@ -440,6 +596,61 @@ func (vNodeController *VNodeController) podDeleteHandler(ctx context.Context, po
 	vNode.DeletePodsFromKubernetesForget(ctx, key)
 }
 
+// nodeAddHandler is an event handler for when a vnode Node object is created.
+// A decommissioned vnode is lifted when its Node is created again, so the vnode is recreated on the next message of its base.
+func (vNodeController *VNodeController) nodeAddHandler(ctx context.Context, node *corev1.Node) {
+	if vNodeController.vNodeStore.LiftDecommission(node.Name) {
+		log.G(ctx).Infof("node of vnode %s is created again, lift the decommission", node.Name)
+	}
+}
+
+// nodeUpdateHandler is an event handler for when a vnode Node object is updated.
+// It passes the cordon and uncordon of the vnode to the tunnel, so the base can stop accepting new biz installations.
+func (vNodeController *VNodeController) nodeUpdateHandler(ctx context.Context, oldNode, newNode *corev1.Node) {
+	if oldNode.Spec.Unschedulable == newNode.Spec.Unschedulable {
+		return
+	}
+
+	observer, ok := vNodeController.tunnel.(tunnel.NodeUnschedulableObserver)
+	if !ok {
+		return
+	}
+	vNode := vNodeController.vNodeStore.GetVNode(newNode.Name)
+	if vNode == nil || !vNode.IsLeader(vNodeController.clientID) {
+		return
+	}
+
+	log.G(ctx).Infof("vnode %s unschedulable changed to %v", newNode.Name, newNode.Spec.Unschedulable)
+	observer.OnNodeUnschedulableChanged(newNode.Name, newNode.Spec.Unschedulable)
+}
+
+// nodeDeleteHandler is an event handler for when a vnode Node object is deleted.
+// The vnode removes itself from the store before deleting the Node, so a vnode still in store means the Node is deleted by user,
+// which is treated as a deliberate decommission: the vnode is shut down and not recreated until the base re-announces itself
+// or the Node is created again.
+func (vNodeController *VNodeController) nodeDeleteHandler(ctx context.Context, node *corev1.Node) {
+	vNode := vNodeController.vNodeStore.GetVNode(node.Name)
+	if vNode == nil {
+		return
+	}
+
+	log.G(ctx).Infof("node of vnode %s is deleted by user, decommission the vnode", node.Name)
+	vNodeController.vNodeStore.DecommissionVNode(node.Name)
+
+	if !vNode.IsLeader(vNodeController.clientID) {
+		// the vnode is not taken over, only drop the local state, the lease and Node belong to the leader
+		vNodeController.vNodeStore.DeleteVNode(node.Name)
+		vNode.Release()
+		return
+	}
+
+	if vNodeController.stopBizOnVNodeDeleted {
+		vNode.StopAllBiz(ctx)
+	}
+	// the vnode will unregister from the tunnel and release the lease after shutdown
+	vNodeController.shutdownVNode(node.Name)
+}
+
 // This function starts a new virtual node with the given node ID, initialization data, and tunnel.
 func (vNodeController *VNodeController) startVNode(initData model.NodeInfo) {
 	vNodeController.Lock()
@@ -448,6 +659,12 @@ func (vNodeController *VNodeController) startVNode(initData model.NodeInfo) {
 	nodeName := initData.Metadata.Name
 	vNode := vNodeController.vNodeStore.GetVNode(nodeName)
 	if vNode != nil {
+		// only the leader patches the node in k8s, the others just keep the node info for taking over
+		ctx := context.WithValue(context.Background(), "nodeName", nodeName)
+		err := vNode.UpdateNodeInfo(ctx, initData, vNode.IsLeader(vNodeController.clientID))
+		if err != nil {
+			log.G(ctx).WithError(err).Errorf("failed to update node info of vnode %s", nodeName)
+		}
 		return
 	}
 
@@ -474,6 +691,9 @@ func (vNodeController *VNodeController) createAndRunVNode(initData model.NodeInf
 			vNodeController.deleteVNode(vnCtx, vNode)
 			log.G(vnCtx).Infof("vnode done: %s, deleted node", vNode.GetNodeName())
 			vnCtxCancel()
+		case <-vNode.Released():
+			log.G(vnCtx).Infof("vnode released: %s", vNode.GetNodeName())
+			vnCtxCancel()
 		}
 	}()
 }
@@ -501,21 +721,27 @@ func (vNodeController *VNodeController) createVNode(vnCtx context.Context, initD
 
 	var vNode *provider.VNode
 	vNode, err = provider.NewVNode(&model.BuildVNodeConfig{
-		Client:            vNodeController.client,
-		KubeCache:         vNodeController.cache,
-		BaseIP:            initData.NetworkInfo.NodeIP,
-		BaseHostName:      initData.NetworkInfo.HostName,
-		NodeIP:            vNodeController.pseudoNodeIP,
-		NodeName:          initData.Metadata.Name,
-		NodeVersion:       initData.Metadata.Version,
-		BaseName:          initData.Metadata.BaseName,
-		VPodType:          vNodeController.vPodType,
-		ClusterName:       initData.Metadata.ClusterName,
-		Env:               vNodeController.env,
-		CustomTaints:      initData.CustomTaints,
-		CustomLabels:      initData.CustomLabels,
-		CustomAnnotations: initData.CustomAnnotations,
-		WorkerNum:         vNodeController.vNodeWorkerNum,
+		Client:              vNodeController.client,
+		KubeCache:           vNodeController.cache,
+		KubeReader:          vNodeController.apiReader,
+		BaseIP:              initData.NetworkInfo.NodeIP,
+		BaseHostName:        initData.NetworkInfo.HostName,
+		NodeIP:              vNodeController.pseudoNodeIP,
+		NodeName:            initData.Metadata.Name,
+		NodeVersion:         initData.Metadata.Version,
+		BaseName:            initData.Metadata.BaseName,
+		VPodType:            vNodeController.vPodType,
+		ClusterName:         initData.Metadata.ClusterName,
+		Env:                 vNodeController.env,
+		CustomTaints:        initData.CustomTaints,
+		CustomLabels:        initData.CustomLabels,
+		CustomAnnotations:   initData.CustomAnnotations,
+		SystemInfo:          initData.SystemInfo,
+		BizContainerMatcher: vNodeController.bizContainerMatcher,
+		BizKeyStrategy:      vNodeController.bizKeyStrategy,
+		StatusMapper:        vNodeController.statusMapper,
+		BizStartTimeout:     vNodeController.bizStartTimeout,
+		WorkerNum:           vNodeController.vNodeWorkerNum,
 	}, vNodeController.tunnel)
 	if err != nil {
 		err = errpkg.Wrap(err, "Error new vnode: "+nodeName)
@@ -692,7 +918,7 @@ func (vNodeController *VNodeController) connectWithInterval(takeOverVnCtx contex
 	})
 
 	go utils.TimedTaskWithInterval(takeOverVnCtx, model.NodeToCheckUnreachableAndDeadStatusInterval*time.Second, func(takeOverVnCtx context.Context) {
-		if vNode.Liveness.IsDead() {
+		if vNode.Liveness.IsDead() && vNodeController.shouldShutdownDeadVNode(vNode) {
 			log.G(takeOverVnCtx).Infof("check and shutdown dead vnode: %s", nodeName)
 			vNodeController.shutdownVNode(vNode.GetNodeName())
 			return
@@ -701,6 +927,11 @@ func (vNodeController *VNodeController) connectWithInterval(takeOverVnCtx contex
 		if !vNode.Liveness.IsReachable() {
 			log.G(takeOverVnCtx).Warnf("node %s is not reachable in interval checking", nodeName)
 		}
+
+		// reflect the liveness to node conditions and taints, so the scheduler and tolerations work as with kubelets
+		if err := vNode.SyncLivenessToNode(takeOverVnCtx); err != nil {
+			log.G(takeOverVnCtx).WithError(err).Errorf("failed to sync liveness of vnode %s", nodeName)
+		}
 	})
 }
 
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -42,6 +43,61 @@ func TestNewVNodeController_Success(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+// keyOwningTunnel is a tunnel which owns the biz unique key
+type keyOwningTunnel struct {
+	tunnel.MockTunnel
+}
+
+func (t *keyOwningTunnel) BizUniqueKey(pod *corev1.Pod, container *corev1.Container) string {
+	return pod.Namespace + "/" + container.Name
+}
+
+func (t *keyOwningTunnel) ParseBizUniqueKey(key string) (*corev1.Container, error) {
+	return &corev1.Container{Name: key}, nil
+}
+
+// legacyKeyTunnel is a tunnel which only computes the biz unique key
+type legacyKeyTunnel struct {
+	tunnel.MockTunnel
+}
+
+func (t *legacyKeyTunnel) GetBizUniqueKey(container *corev1.Container) string {
+	return container.Name + ":legacy"
+}
+
+func TestNewVNodeController_BizKeyStrategy(t *testing.T) {
+	vc, err := NewVNodeController(&model.BuildVNodeControllerConfig{
+		VPodType:  "suite",
+		KubeCache: &informertest.FakeInformers{},
+	}, &tunnel.MockTunnel{})
+	assert.NoError(t, err)
+	assert.Equal(t, utils.DefaultBizKeyStrategy, vc.bizKeyStrategy)
+
+	keyTunnel := &keyOwningTunnel{}
+	vc, err = NewVNodeController(&model.BuildVNodeControllerConfig{
+		VPodType:  "suite",
+		KubeCache: &informertest.FakeInformers{},
+	}, keyTunnel)
+	assert.NoError(t, err)
+	assert.Equal(t, keyTunnel, vc.bizKeyStrategy)
+
+	// the key of tunnels written before the key strategy is still computed by them
+	vc, err = NewVNodeController(&model.BuildVNodeControllerConfig{
+		VPodType:  "suite",
+		KubeCache: &informertest.FakeInformers{},
+	}, &legacyKeyTunnel{})
+	assert.NoError(t, err)
+	assert.Equal(t, "biz1:legacy", vc.bizKeyStrategy.BizUniqueKey(&corev1.Pod{}, &corev1.Container{Name: "biz1"}))
+
+	vc, err = NewVNodeController(&model.BuildVNodeControllerConfig{
+		VPodType:       "suite",
+		KubeCache:      &informertest.FakeInformers{},
+		BizKeyStrategy: utils.NameVersionBizKeyStrategy{},
+	}, keyTunnel)
+	assert.NoError(t, err)
+	assert.Equal(t, utils.NameVersionBizKeyStrategy{}, vc.bizKeyStrategy)
+}
+
 func TestDiscoverPreviousNode(t *testing.T) {
 	mockTunnel := tunnel.MockTunnel{}
 
@@ -326,6 +382,33 @@ func TestShutdownNonExistVNode(t *testing.T) {
 	vc.shutdownVNode("test-node")
 }
 
+func TestShouldShutdownDeadVNode(t *testing.T) {
+	mockTunnel := tunnel.MockTunnel{}
+	vc, _ := NewVNodeController(&model.BuildVNodeControllerConfig{
+		VPodType:      "suite",
+		KubeCache:     &informertest.FakeInformers{},
+		KeepDeadVNode: true,
+	}, &mockTunnel)
+	assert.Equal(t, model.DeadVNodeRetentionSeconds*time.Second, vc.deadVNodeRetention)
+
+	vNode := &provider.VNode{}
+	vNode.Liveness.LatestHeartBeatTime = time.Now().Add(-(model.NodeToDeadMaxSeconds + 1) * time.Second)
+	assert.False(t, vc.shouldShutdownDeadVNode(vNode))
+
+	// the dead vnode is not kept beyond the retention
+	vNode.Liveness.LatestHeartBeatTime = time.Now().Add(-(model.NodeToDeadMaxSeconds+1)*time.Second - vc.deadVNodeRetention)
+	assert.True(t, vc.shouldShutdownDeadVNode(vNode))
+
+	// the deactivated vnode is shutdown after the drain grace period
+	vNode.Liveness.Deactivate()
+	assert.False(t, vc.shouldShutdownDeadVNode(vNode))
+
+	vc.keepDeadVNode = false
+	vNode.Liveness.UpdateHeartBeatTime()
+	vNode.Liveness.Close()
+	assert.True(t, vc.shouldShutdownDeadVNode(vNode))
+}
+
 func TestDeleteGraceTimeEqual(t *testing.T) {
 	assert.True(t, deleteGraceTimeEqual(nil, nil))
 	assert.False(t, deleteGraceTimeEqual(ptr.To[int64](1), nil))
@@ -379,3 +462,88 @@ func TestPodShouldEnqueue(t *testing.T) {
 		},
 	}))
 }
+
+func TestNodeUpdateHandler_Unschedulable(t *testing.T) {
+	mockTunnel := tunnel.MockTunnel{}
+	_ = mockTunnel.Start("test", "test")
+	vc, _ := NewVNodeController(&model.BuildVNodeControllerConfig{
+		VPodType:  "suite",
+		ClientID:  "mockClientID",
+		KubeCache: &informertest.FakeInformers{},
+	}, &mockTunnel)
+
+	oldNode := &corev1.Node{
+		ObjectMeta: v1.ObjectMeta{Name: "test-node"},
+	}
+	newNode := oldNode.DeepCopy()
+	newNode.Spec.Unschedulable = true
+
+	// vnode not exist
+	vc.nodeUpdateHandler(context.TODO(), oldNode, newNode)
+	_, has := mockTunnel.NodeUnschedulable["test-node"]
+	assert.False(t, has)
+
+	vn := &provider.VNode{}
+	vn.SetLease(vn.NewLease("mockClientID"))
+	vc.vNodeStore.AddVNode("test-node", vn)
+
+	vc.nodeUpdateHandler(context.TODO(), oldNode, newNode)
+	assert.True(t, mockTunnel.NodeUnschedulable["test-node"])
+
+	vc.nodeUpdateHandler(context.TODO(), newNode, oldNode)
+	assert.False(t, mockTunnel.NodeUnschedulable["test-node"])
+}
+
+func TestNodeDeleteHandler_Decommission(t *testing.T) {
+	mockTunnel := tunnel.MockTunnel{}
+	_ = mockTunnel.Start("test", "test")
+	vc, _ := NewVNodeController(&model.BuildVNodeControllerConfig{
+		VPodType:  "suite",
+		ClientID:  "mockClientID",
+		KubeCache: &informertest.FakeInformers{},
+	}, &mockTunnel)
+	vc.cache = &informertest.FakeInformers{}
+
+	node := &corev1.Node{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "vnode.test-node",
+			Labels: map[string]string{
+				model.LabelKeyOfComponent: model.ComponentVNode,
+			},
+		},
+	}
+	vc.client = fake.NewFakeClient(node.DeepCopy())
+	nodeInfo := utils.ConvertNodeToNodeInfo(node)
+
+	// vnode not exist, nothing to do
+	vc.nodeDeleteHandler(context.TODO(), node)
+	assert.False(t, vc.vNodeStore.CheckDecommissionedOnProviderArrived(node.Name, model.NodeStateActivated))
+
+	vnCtx, vnCtxCancel := context.WithCancel(context.Background())
+	defer vnCtxCancel()
+	vNode, err := vc.createVNode(vnCtx, nodeInfo)
+	assert.NoError(t, err)
+
+	// the vnode is not taken over, only the local state is dropped
+	vc.nodeDeleteHandler(context.TODO(), node)
+	assert.Nil(t, vc.vNodeStore.GetVNode(node.Name))
+	select {
+	case <-vNode.Released():
+	default:
+		assert.Fail(t, "vnode not taken over should be released")
+	}
+	select {
+	case <-vNode.Done():
+		assert.Fail(t, "vnode not taken over should not be done")
+	default:
+	}
+	assert.NoError(t, vc.client.Get(context.TODO(), types.NamespacedName{Name: node.Name}, &corev1.Node{}))
+
+	// the decommissioned vnode will not be recreated
+	vc.onBaseDiscovered(nodeInfo)
+	assert.Nil(t, vc.vNodeStore.GetVNode(node.Name))
+
+	// the decommission is lifted when the node is created again
+	vc.nodeAddHandler(context.TODO(), node)
+	assert.False(t, vc.vNodeStore.CheckDecommissionedOnProviderArrived(node.Name, model.NodeStateActivated))
+}
@@ -0,0 +1,162 @@
+/**
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vpod_webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/koupleless/virtual-kubelet/common/utils"
+	"github.com/koupleless/virtual-kubelet/model"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// VPodMutatingWebhookPath is the path of the vpod mutating webhook, the MutatingWebhookConfiguration should point to it for pod CREATE
+const VPodMutatingWebhookPath = "/mutate-vpod"
+
+var _ admission.Handler = &VPodMutator{}
+
+// VPodMutator injects the tolerations of vnode taints and the node affinity of the selected base into vpods
+type VPodMutator struct {
+	vPodType string
+	env      string
+	decoder  admission.Decoder
+}
+
+// NewVPodMutator creates a mutator for the pods with the vpod type as model.LabelKeyOfComponent, env is used for vpods without env label
+func NewVPodMutator(vPodType, env string, decoder admission.Decoder) *VPodMutator {
+	return &VPodMutator{
+		vPodType: vPodType,
+		env:      env,
+		decoder:  decoder,
+	}
+}
+
+// SetupWithManager registers the mutator to the webhook server of the manager
+func (m *VPodMutator) SetupWithManager(mgr manager.Manager) {
+	if m.decoder == nil {
+		m.decoder = admission.NewDecoder(mgr.GetScheme())
+	}
+	mgr.GetWebhookServer().Register(VPodMutatingWebhookPath, &webhook.Admission{Handler: m})
+}
+
+// Handle patches the vpod in the admission request
+func (m *VPodMutator) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+
+	pod := &corev1.Pod{}
+	if err := m.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if pod.Labels[model.LabelKeyOfComponent] != m.vPodType {
+		return admission.Allowed("not a vpod")
+	}
+
+	MutateVPod(pod, utils.OrElse(pod.Labels[model.LabelKeyOfEnv], m.env))
+
+	marshaledPod, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+}
+
+// MutateVPod adds the tolerations of vnode taints of the env, and the required node affinity of vnodes of the env and the base selected by annotations
+func MutateVPod(pod *corev1.Pod, env string) {
+	for _, toleration := range utils.VNodeTolerations(env) {
+		if !hasToleration(pod.Spec.Tolerations, toleration) {
+			pod.Spec.Tolerations = append(pod.Spec.Tolerations, toleration)
+		}
+	}
+
+	requirements := []corev1.NodeSelectorRequirement{
+		{
+			Key:      model.LabelKeyOfComponent,
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{model.ComponentVNode},
+		},
+		{
+			Key:      model.LabelKeyOfEnv,
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{env},
+		},
+	}
+	annotationToLabel := []struct {
+		annotationKey string
+		labelKey      string
+	}{
+		{model.AnnotationKeyOfBaseName, model.LabelKeyOfBaseName},
+		{model.AnnotationKeyOfBaseVersion, model.LabelKeyOfBaseVersion},
+		{model.AnnotationKeyOfBaseClusterName, model.LabelKeyOfBaseClusterName},
+	}
+	for _, item := range annotationToLabel {
+		if value := pod.Annotations[item.annotationKey]; value != "" {
+			requirements = append(requirements, corev1.NodeSelectorRequirement{
+				Key:      item.labelKey,
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   []string{value},
+			})
+		}
+	}
+	addRequiredNodeAffinity(pod, requirements)
+}
+
+func hasToleration(tolerations []corev1.Toleration, toleration corev1.Toleration) bool {
+	for _, t := range tolerations {
+		if t.MatchToleration(&toleration) {
+			return true
+		}
+	}
+	return false
+}
+
+// addRequiredNodeAffinity adds the requirements to every required node selector term, terms are ORed so each of them must carry the requirements,
+// requirements of keys already in a term are left to the user
+func addRequiredNodeAffinity(pod *corev1.Pod, requirements []corev1.NodeSelectorRequirement) {
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	nodeAffinity := pod.Spec.Affinity.NodeAffinity
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+	}
+	nodeSelector := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(nodeSelector.NodeSelectorTerms) == 0 {
+		nodeSelector.NodeSelectorTerms = []corev1.NodeSelectorTerm{{}}
+	}
+
+	for i := range nodeSelector.NodeSelectorTerms {
+		term := &nodeSelector.NodeSelectorTerms[i]
+		existingKeys := make(map[string]bool)
+		for _, expression := range term.MatchExpressions {
+			existingKeys[expression.Key] = true
+		}
+		for _, requirement := range requirements {
+			if !existingKeys[requirement.Key] {
+				term.MatchExpressions = append(term.MatchExpressions, requirement)
+			}
+		}
+	}
+}
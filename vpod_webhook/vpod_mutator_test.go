@@ -0,0 +1,63 @@
+package vpod_webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/koupleless/virtual-kubelet/common/utils"
+	"github.com/koupleless/virtual-kubelet/model"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestMutateVPod(t *testing.T) {
+	pod := buildVPod()
+	pod.Annotations = map[string]string{
+		model.AnnotationKeyOfBaseName:    "base",
+		model.AnnotationKeyOfBaseVersion: "1.0.0",
+	}
+	pod.Spec.Affinity = &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: model.LabelKeyOfBaseVersion, Operator: corev1.NodeSelectorOpExists}}},
+					{},
+				},
+			},
+		},
+	}
+	MutateVPod(pod, "test")
+	// mutation is idempotent
+	MutateVPod(pod, "test")
+
+	assert.ElementsMatch(t, utils.VNodeTolerations("test"), pod.Spec.Tolerations)
+	for _, taint := range utils.VNodeTaints("test") {
+		tolerated := false
+		for _, toleration := range pod.Spec.Tolerations {
+			tolerated = tolerated || toleration.ToleratesTaint(&taint)
+		}
+		assert.True(t, tolerated)
+	}
+
+	terms := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	assert.Len(t, terms, 2)
+	assert.Len(t, terms[0].MatchExpressions, 4)
+	assert.Equal(t, corev1.NodeSelectorOpExists, terms[0].MatchExpressions[0].Operator)
+	assert.Len(t, terms[1].MatchExpressions, 4)
+}
+
+func TestVPodMutator_Handle(t *testing.T) {
+	mutator := NewVPodMutator("suite", "test", admission.NewDecoder(scheme.Scheme))
+
+	resp := mutator.Handle(context.Background(), buildAdmissionRequest(t, buildVPod()))
+	assert.True(t, resp.Allowed)
+	assert.NotEmpty(t, resp.Patches)
+
+	pod := buildVPod()
+	pod.Labels = nil
+	resp = mutator.Handle(context.Background(), buildAdmissionRequest(t, pod))
+	assert.True(t, resp.Allowed)
+	assert.Empty(t, resp.Patches)
+}
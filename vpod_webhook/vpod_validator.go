@@ -0,0 +1,185 @@
+/**
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vpod_webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/koupleless/virtual-kubelet/common/utils"
+	"github.com/koupleless/virtual-kubelet/model"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// VPodValidatingWebhookPath is the path of the vpod validating webhook, the ValidatingWebhookConfiguration should point to it for pod CREATE and UPDATE
+const VPodValidatingWebhookPath = "/validate-vpod"
+
+var _ admission.Handler = &VPodValidator{}
+
+// VPodValidator rejects the vpods which the vpod provider can't run, pods without the vpod component label are always allowed
+type VPodValidator struct {
+	vPodType            string
+	bizContainerMatcher model.BizContainerMatcher
+	bizKeyStrategy      model.BizKeyStrategy
+	decoder             admission.Decoder
+}
+
+// NewVPodValidator creates a validator for the pods with the vpod type as model.LabelKeyOfComponent,
+// bizContainerMatcher and bizKeyStrategy should be the same as the vnode controller,
+// nil means utils.DefaultBizContainerMatcher and utils.DefaultBizKeyStrategy
+func NewVPodValidator(vPodType string, bizContainerMatcher model.BizContainerMatcher, bizKeyStrategy model.BizKeyStrategy, decoder admission.Decoder) *VPodValidator {
+	if bizContainerMatcher == nil {
+		bizContainerMatcher = utils.DefaultBizContainerMatcher
+	}
+	if bizKeyStrategy == nil {
+		bizKeyStrategy = utils.DefaultBizKeyStrategy
+	}
+	return &VPodValidator{
+		vPodType:            vPodType,
+		bizContainerMatcher: bizContainerMatcher,
+		bizKeyStrategy:      bizKeyStrategy,
+		decoder:             decoder,
+	}
+}
+
+// SetupWithManager registers the validator to the webhook server of the manager
+func (v *VPodValidator) SetupWithManager(mgr manager.Manager) {
+	if v.decoder == nil {
+		v.decoder = admission.NewDecoder(mgr.GetScheme())
+	}
+	mgr.GetWebhookServer().Register(VPodValidatingWebhookPath, &webhook.Admission{Handler: v})
+}
+
+// Handle validates the vpod in the admission request
+func (v *VPodValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	pod := &corev1.Pod{}
+	if err := v.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if pod.Labels[model.LabelKeyOfComponent] != v.vPodType {
+		return admission.Allowed("not a vpod")
+	}
+
+	var errs field.ErrorList
+	if req.Operation == admissionv1.Update {
+		oldPod := &corev1.Pod{}
+		if err := v.decoder.DecodeRaw(req.OldObject, oldPod); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		errs = ValidateVPodUpdate(oldPod, pod, v.bizContainerMatcher, v.bizKeyStrategy)
+	} else {
+		errs = ValidateVPod(pod, v.bizContainerMatcher, v.bizKeyStrategy)
+	}
+	if len(errs) > 0 {
+		log.G(ctx).Warnf("vpod %s/%s rejected: %s", req.Namespace, pod.Name, errs.ToAggregate().Error())
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+	return admission.Allowed("")
+}
+
+// ValidateVPod checks the vpod against the rules the vpod provider relies on:
+// at least one container is a biz matched by the matcher, each biz can be restored from its key by the key strategy,
+// container names are unique, and no volumes or init containers are used. Containers not matched are sidecars and never installed.
+func ValidateVPod(pod *corev1.Pod, bizContainerMatcher model.BizContainerMatcher, bizKeyStrategy model.BizKeyStrategy) field.ErrorList {
+	return validateVPod(nil, pod, bizContainerMatcher, bizKeyStrategy)
+}
+
+// ValidateVPodUpdate checks the fields changed by the update with the same rules as ValidateVPod,
+// so the vpods admitted before can always be updated, e.g. to remove the finalizers
+func ValidateVPodUpdate(oldPod, pod *corev1.Pod, bizContainerMatcher model.BizContainerMatcher, bizKeyStrategy model.BizKeyStrategy) field.ErrorList {
+	return validateVPod(oldPod, pod, bizContainerMatcher, bizKeyStrategy)
+}
+
+// validateVPod validates all fields of the pod if oldPod is nil, otherwise only the fields changed from oldPod
+func validateVPod(oldPod, pod *corev1.Pod, bizContainerMatcher model.BizContainerMatcher, bizKeyStrategy model.BizKeyStrategy) field.ErrorList {
+	errs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+
+	if len(pod.Spec.InitContainers) > 0 && (oldPod == nil || !reflect.DeepEqual(oldPod.Spec.InitContainers, pod.Spec.InitContainers)) {
+		errs = append(errs, field.Forbidden(specPath.Child("initContainers"), "init containers are not supported by vpod"))
+	}
+	if len(pod.Spec.EphemeralContainers) > 0 && (oldPod == nil || !reflect.DeepEqual(oldPod.Spec.EphemeralContainers, pod.Spec.EphemeralContainers)) {
+		errs = append(errs, field.Forbidden(specPath.Child("ephemeralContainers"), "ephemeral containers are not supported by vpod"))
+	}
+	if len(pod.Spec.Volumes) > 0 && (oldPod == nil || !reflect.DeepEqual(oldPod.Spec.Volumes, pod.Spec.Volumes)) {
+		errs = append(errs, field.Forbidden(specPath.Child("volumes"), "volumes are not supported by vpod"))
+	}
+
+	oldContainers := make(map[string]corev1.Container)
+	if oldPod != nil {
+		for _, container := range oldPod.Spec.Containers {
+			oldContainers[container.Name] = container
+		}
+	}
+	if oldPod == nil && !hasBizContainer(pod, bizContainerMatcher) {
+		errs = append(errs, field.Required(specPath.Child("containers"), "at least one container must be recognized as a biz module"))
+	}
+	containerNames := make(map[string]bool)
+	for i, container := range pod.Spec.Containers {
+		containerPath := specPath.Child("containers").Index(i)
+		duplicated := containerNames[container.Name]
+		containerNames[container.Name] = true
+		if oldContainer, has := oldContainers[container.Name]; has && !duplicated && reflect.DeepEqual(oldContainer, container) {
+			continue
+		}
+
+		if duplicated {
+			errs = append(errs, field.Duplicate(containerPath.Child("name"), container.Name))
+		}
+		if bizContainerMatcher.IsBizContainer(pod, &container) {
+			errs = append(errs, validateBizKey(containerPath, pod, &container, bizKeyStrategy)...)
+		}
+		if len(container.VolumeMounts) > 0 {
+			errs = append(errs, field.Forbidden(containerPath.Child("volumeMounts"), "volume mounts are not supported by vpod"))
+		}
+	}
+	return errs
+}
+
+// hasBizContainer checks whether any container of the pod is a biz module, a vpod without biz succeeds at once
+func hasBizContainer(pod *corev1.Pod, bizContainerMatcher model.BizContainerMatcher) bool {
+	for _, container := range pod.Spec.Containers {
+		if bizContainerMatcher.IsBizContainer(pod, &container) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateBizKey checks the biz can be restored from its unique key, which is required to uninstall the biz whose vpod is gone,
+// what the key is made of is up to the key strategy
+func validateBizKey(containerPath *field.Path, pod *corev1.Pod, container *corev1.Container, bizKeyStrategy model.BizKeyStrategy) field.ErrorList {
+	key := bizKeyStrategy.BizUniqueKey(pod, container)
+	parsed, err := bizKeyStrategy.ParseBizUniqueKey(key)
+	if err != nil {
+		return field.ErrorList{field.Invalid(containerPath, key, fmt.Sprintf("biz key can't be parsed: %v", err))}
+	}
+	if restored := bizKeyStrategy.BizUniqueKey(pod, parsed); restored != key {
+		return field.ErrorList{field.Invalid(containerPath, key, fmt.Sprintf("biz key is restored as %q", restored))}
+	}
+	return nil
+}
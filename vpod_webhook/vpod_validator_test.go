@@ -0,0 +1,162 @@
+package vpod_webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/koupleless/virtual-kubelet/common/utils"
+	"github.com/koupleless/virtual-kubelet/model"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func buildVPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "default",
+			Labels:    map[string]string{model.LabelKeyOfComponent: "suite"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "biz1",
+					Image: "biz1.jar",
+					Env:   []corev1.EnvVar{{Name: "BIZ_VERSION", Value: "0.0.1"}},
+				},
+			},
+		},
+	}
+}
+
+func buildAdmissionRequest(t *testing.T, pod *corev1.Pod) admission.Request {
+	raw, err := json.Marshal(pod)
+	assert.NoError(t, err)
+	return admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Namespace: pod.Namespace,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	}
+}
+
+func TestValidateVPod(t *testing.T) {
+	assert.Len(t, ValidateVPod(buildVPod(), utils.DefaultBizContainerMatcher, utils.DefaultBizKeyStrategy), 0)
+
+	pod := buildVPod()
+	pod.Spec.InitContainers = []corev1.Container{{Name: "init"}}
+	pod.Spec.Volumes = []corev1.Volume{{Name: "data"}}
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+		Name:  "biz1",
+		Image: "biz1",
+	}, corev1.Container{
+		Name:  "biz2",
+		Image: "biz2.jar",
+	})
+	errs := ValidateVPod(pod, utils.DefaultBizContainerMatcher, utils.DefaultBizKeyStrategy)
+	fields := make([]string, 0)
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	assert.ElementsMatch(t, []string{
+		"spec.initContainers",
+		"spec.volumes",
+		"spec.containers[1].name",
+		"spec.containers[2]",
+	}, fields)
+
+	// the sidecar is never installed as biz, but a vpod needs a biz
+	pod = buildVPod()
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "sidecar", Image: "sidecar"})
+	assert.Len(t, ValidateVPod(pod, utils.DefaultBizContainerMatcher, utils.DefaultBizKeyStrategy), 0)
+	pod.Spec.Containers = pod.Spec.Containers[1:]
+	errs = ValidateVPod(pod, utils.DefaultBizContainerMatcher, utils.DefaultBizKeyStrategy)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "spec.containers", errs[0].Field)
+}
+
+// imageBizKeyStrategy identifies the biz by the image, a tenant prefix of the image is dropped when parsed
+type imageBizKeyStrategy struct{}
+
+func (imageBizKeyStrategy) BizUniqueKey(_ *corev1.Pod, container *corev1.Container) string {
+	return container.Name + "@" + container.Image
+}
+
+func (imageBizKeyStrategy) ParseBizUniqueKey(key string) (*corev1.Container, error) {
+	name, image, found := strings.Cut(key, "@")
+	if !found {
+		return nil, fmt.Errorf("unexpected biz key format: %q", key)
+	}
+	return &corev1.Container{Name: name, Image: strings.TrimPrefix(image, "tenant/")}, nil
+}
+
+func TestValidateVPod_BizKeyStrategy(t *testing.T) {
+	pod := buildVPod()
+	pod.Spec.Containers[0].Name = "biz:1"
+	errs := ValidateVPod(pod, utils.DefaultBizContainerMatcher, utils.DefaultBizKeyStrategy)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "spec.containers[0]", errs[0].Field)
+	assert.Len(t, ValidateVPod(pod, utils.DefaultBizContainerMatcher, imageBizKeyStrategy{}), 0)
+
+	// BIZ_VERSION is only required by the default strategy
+	pod = buildVPod()
+	pod.Spec.Containers[0].Env = nil
+	assert.Len(t, ValidateVPod(pod, utils.DefaultBizContainerMatcher, utils.DefaultBizKeyStrategy), 1)
+	assert.Len(t, ValidateVPod(pod, utils.DefaultBizContainerMatcher, imageBizKeyStrategy{}), 0)
+
+	// the biz must be restored with the same key
+	pod.Spec.Containers[0].Image = "tenant/biz1.jar"
+	errs = ValidateVPod(pod, utils.DefaultBizContainerMatcher, imageBizKeyStrategy{})
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "spec.containers[0]", errs[0].Field)
+}
+
+func TestValidateVPodUpdate(t *testing.T) {
+	// the pod admitted before the volume is forbidden
+	oldPod := buildVPod()
+	oldPod.Spec.Volumes = []corev1.Volume{{Name: "data"}}
+	oldPod.Spec.Containers = append(oldPod.Spec.Containers, corev1.Container{Name: "sidecar", Image: "sidecar"})
+
+	pod := oldPod.DeepCopy()
+	pod.Finalizers = nil
+	pod.Labels["updated"] = "true"
+	assert.Len(t, ValidateVPodUpdate(oldPod, pod, utils.DefaultBizContainerMatcher, utils.DefaultBizKeyStrategy), 0)
+
+	pod.Spec.Containers[0].Env = nil
+	errs := ValidateVPodUpdate(oldPod, pod, utils.DefaultBizContainerMatcher, utils.DefaultBizKeyStrategy)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "spec.containers[0]", errs[0].Field)
+}
+
+func TestVPodValidator_Handle(t *testing.T) {
+	validator := NewVPodValidator("suite", nil, nil, admission.NewDecoder(scheme.Scheme))
+
+	resp := validator.Handle(context.Background(), buildAdmissionRequest(t, buildVPod()))
+	assert.True(t, resp.Allowed)
+
+	pod := buildVPod()
+	pod.Spec.Containers[0].Env = nil
+	resp = validator.Handle(context.Background(), buildAdmissionRequest(t, pod))
+	assert.False(t, resp.Allowed)
+	assert.Contains(t, resp.Result.Message, "BIZ_VERSION")
+
+	// only the changed fields are validated on update
+	req := buildAdmissionRequest(t, pod)
+	req.Operation = admissionv1.Update
+	req.OldObject = req.Object
+	resp = validator.Handle(context.Background(), req)
+	assert.True(t, resp.Allowed)
+
+	pod.Labels = nil
+	resp = validator.Handle(context.Background(), buildAdmissionRequest(t, pod))
+	assert.True(t, resp.Allowed)
+}